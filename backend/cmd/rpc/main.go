@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,8 +14,14 @@ import (
 	"time"
 
 	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/firebase"
+	"github.com/httptest/backend/pkg/health"
 	"github.com/httptest/backend/pkg/logger"
+	"github.com/httptest/backend/pkg/metrics"
+	"github.com/httptest/backend/pkg/ratelimit"
+	"github.com/httptest/backend/pkg/tracing"
 	"github.com/httptest/backend/pkg/util"
+	"github.com/httptest/backend/rpc/handler"
 	"github.com/httptest/backend/rpc/injector"
 	"github.com/inconshreveable/log15"
 )
@@ -27,14 +35,42 @@ func init() {
 }
 
 func main() {
+	listRoles := flag.Bool("list-roles", false, "print the methods each role can call, then exit")
+	flag.Parse()
+
 	c := config.Prepare()
 	logger.InitLogger(c.Logger)
 
+	if *listRoles {
+		if err := printRoleMethods(c); err != nil {
+			log.Fatalln("Failed to list roles:", err)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), c.Tracing, applicationName)
+	if err != nil {
+		log.Fatalln("Failed to initialize tracing:", err)
+	}
+
 	log15.Info("listening....", "method", "main.init", "port", c.HTTP.Port)
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", c.HTTP.Port),
-		Handler: mux(c),
+		Addr: fmt.Sprintf(":%d", c.HTTP.Port),
+	}
+	// 公開鍵のキャッシュを共有するため、ハンドラとヘルスチェックで同じVerifierを使う
+	verifier := firebase.NewVerifier(c.Firebase)
+	checker, closeDB, err := injector.InitializeHealthChecker(c.Health, c.Postgres, verifier, "wdc-rpc-health")
+	if err != nil {
+		log.Fatalln("Failed to initialize health checker:", err)
 	}
+	defer closeDB()
+	handler, err := mux(c, srv, verifier, checker)
+	if err != nil {
+		log.Fatalln("Failed to initialize handlers:", err)
+	}
+	srv.Handler = handler
+	metricsSrv := metrics.NewServer(c.Metrics)
+	go metrics.Serve(metricsSrv)
 	go func() {
 		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
 			// Error starting or closing listener:
@@ -46,19 +82,66 @@ func main() {
 	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
 	log.Printf("SIGNAL %d received, then shutting down...\n", <-quit)
 
+	// ロードバランサが/readyzの503を検知して振り分けを止めるまで待つ
+	checker.SetNotReady()
+	time.Sleep(c.Health.ShutdownDelay)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		// Error from closing listeners, or context timeout:
 		log15.Error("Failed to gracefully shutdown:", err)
 	}
+	metrics.Shutdown(ctx, metricsSrv)
+	if err := shutdownTracing(ctx); err != nil {
+		log15.Error("Failed to flush traces", "err", err)
+	}
 	log15.Info("Server shutdown")
 }
 
-func mux(c config.AppConfig) *http.ServeMux {
-	firebaseHandler := injector.InitializeFirebaseHandler(c.HTTP, c.Postgres, c.Firebase, "wdc-rpc-firebase")
+// printRoleMethods : ロール毎に実行できるメソッドをJSONで出力する (監査用)
+func printRoleMethods(c config.AppConfig) error {
+	funcMap := injector.InitializeFirebaseMap(c.Postgres, "wdc-rpc-list-roles")
+	b, err := json.MarshalIndent(handler.RoleMethods(funcMap), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func mux(c config.AppConfig, srv *http.Server, verifier firebase.Verifier, checker *health.Checker) (*http.ServeMux, error) {
+	// 同じ呼び出し元がエンドポイントを変えて上限を回避できないよう、全てのエンドポイントで共有する
+	rateLimiter := ratelimit.NewStore()
+	firebaseHandler, err := injector.InitializeFirebaseHandler(c.HTTP, c.Postgres, verifier, "wdc-rpc-firebase", rateLimiter)
+	if err != nil {
+		return nil, err
+	}
+	publicHandler, err := injector.InitializePublicHandler(c.HTTP, c.Postgres, "wdc-rpc-public", rateLimiter)
+	if err != nil {
+		return nil, err
+	}
+	internalHandler, err := injector.InitializeInternalHandler(c.HTTP, c.Internal, c.Postgres, "wdc-rpc-internal", rateLimiter)
+	if err != nil {
+		return nil, err
+	}
+	webSocketHandler, err := injector.InitializeWebSocketHandler(c.HTTP, c.Postgres, verifier, "wdc-rpc-websocket", rateLimiter)
+	if err != nil {
+		return nil, err
+	}
+	// Shutdownはhijackされた接続を閉じないため、WebSocketは個別に閉じる
+	srv.RegisterOnShutdown(webSocketHandler.Shutdown)
+	openRPCHandler := injector.InitializeOpenRPCHandler(c.HTTP, c.Postgres, "wdc-rpc-openrpc")
 
 	mux := http.NewServeMux()
 	mux.Handle("/", firebaseHandler)
-	return mux
+	mux.Handle("/public", publicHandler)
+	mux.Handle("/internal", internalHandler)
+	mux.Handle("/ws", webSocketHandler)
+	mux.Handle("/openrpc.json", openRPCHandler)
+	mux.Handle("/healthz", checker.LivenessHandler())
+	mux.Handle("/readyz", checker.ReadinessHandler())
+	// ShutdownDelayを待たずにShutdownされた場合も、以降の/readyzは503を返す
+	srv.RegisterOnShutdown(checker.SetNotReady)
+	return mux, nil
 }
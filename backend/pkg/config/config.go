@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -21,12 +22,74 @@ type Config struct {
 type HTTP struct {
 	Cors string `mapstructure:"cors" validate:"required"`
 	Port int    `mapstructure:"port" validate:"required"`
+	// バッチリクエストを並列に実行する上限数 (0以下は直列実行)
+	Concurrency int       `mapstructure:"concurrency"`
+	WebSocket   WebSocket `mapstructure:"websocket"`
+	// リクエストの上限 (0以下は無制限)
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"`
+	MaxBatchSize int   `mapstructure:"max_batch_size"`
+	MaxDepth     int   `mapstructure:"max_depth"`
+	// MethodTimeout : メソッド毎の実行時間の上限 (0以下は無制限)
+	// ctxの期限として渡すため、メソッドがctxに従わない場合は止まらない
+	MethodTimeout time.Duration `mapstructure:"method_timeout"`
+	// TrustedProxies : ForwardedHeaderを信頼するプロキシのCIDR
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// ForwardedHeader : クライアントのIPアドレスを読むヘッダ (x-forwarded-forまたはforwarded、空はx-forwarded-for)
+	// プロキシが追記するヘッダを1つだけ指定する
+	ForwardedHeader string `mapstructure:"forwarded_header"`
+	// RateLimit : 呼び出し元 (ユーザ、端末、IPアドレス) 毎の呼び出し数の上限
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+	// AuthRateLimit : IPアドレス毎の認証に失敗したリクエスト数の上限、認証の前に確認する (認証の総当たり対策)
+	AuthRateLimit RateLimit `mapstructure:"auth_rate_limit"`
+}
+
+// RateLimit : 1秒あたりrate回、最大burst回まで続けて呼び出せる (rateが0以下は無制限)
+type RateLimit struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// WebSocket :
+type WebSocket struct {
+	PingPeriod time.Duration `mapstructure:"ping_period"`
+	PongWait   time.Duration `mapstructure:"pong_wait"`
+	WriteWait  time.Duration `mapstructure:"write_wait"`
+	ReadLimit  int64         `mapstructure:"read_limit"`
 }
 
 // Logger :
 type Logger struct {
 	Debug   bool `mapstructure:"debug"`
 	LogJSON bool `mapstructure:"log_json"`
+	// SuccessSampleRate : 成功した呼び出しのアクセスログを出力する割合 (未設定と1以上は全て、0以下は出力しない)
+	SuccessSampleRate *float64 `mapstructure:"success_sample_rate"`
+}
+
+// Metrics : Prometheusの/metricsを提供する (portが0以下は提供しない)
+type Metrics struct {
+	Port int    `mapstructure:"port"`
+	Path string `mapstructure:"path"`
+}
+
+// Tracing : OpenTelemetryのspanの送信先
+type Tracing struct {
+	// Exporter : none, otlp, stdout, file
+	Exporter string `mapstructure:"exporter"`
+	// Endpoint, Insecure : otlpの送信先 (host:port)
+	Endpoint string `mapstructure:"endpoint"`
+	Insecure bool   `mapstructure:"insecure"`
+	// FilePath : fileの出力先
+	FilePath string `mapstructure:"file_path"`
+	// SampleRatio : 記録するtraceの割合 (0以下、1以上は全て記録)
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// Health : /readyzの確認
+type Health struct {
+	// Timeout : 依存先毎の確認の上限
+	Timeout time.Duration `mapstructure:"timeout"`
+	// ShutdownDelay : 終了時に/readyzを503にしてからShutdownするまでの時間
+	ShutdownDelay time.Duration `mapstructure:"shutdown_delay"`
 }
 
 // Postgres :
@@ -37,21 +100,42 @@ type Postgres struct {
 	Port    string `mapstructure:"port" validate:"required"`
 	Sslmode string `mapstructure:"sslmode" validate:"required"`
 	User    string `mapstructure:"user" validate:"required"`
-	Pseudo  bool
+	// コネクションプール (0はデフォルト値、負の値はdatabase/sqlにそのまま渡す)
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	// 起動時の接続のリトライ回数と最初の待ち時間 (リトライ毎に倍にする)
+	ConnectRetries int           `mapstructure:"connect_retries"`
+	ConnectBackoff time.Duration `mapstructure:"connect_backoff"`
+	Pseudo         bool
 }
 
 // Firebase :
 type Firebase struct {
 	CredentialKey string `mapstructure:"credential_key" validate:"required"`
-	Pseudo        bool
+	// ProjectID : IDトークンのaud, issの検証に使う
+	ProjectID string `mapstructure:"project_id" validate:"required"`
+	// KeysURL : 公開鍵 (JWKS) の取得先、空の場合はGoogleの公開鍵
+	KeysURL string `mapstructure:"keys_url"`
+	Pseudo  bool
+}
+
+// Internal : 社内サービス向けエンドポイントの認証情報
+type Internal struct {
+	Credential string `mapstructure:"credential" validate:"required"`
 }
 
 // AppConfig :
 type AppConfig struct {
 	HTTP     HTTP     `mapstructure:"http"`
 	Logger   Logger   `mapstructure:"logger"`
+	Metrics  Metrics  `mapstructure:"metrics"`
+	Tracing  Tracing  `mapstructure:"tracing"`
+	Health   Health   `mapstructure:"health"`
 	Postgres Postgres `mapstructure:"postgres"`
 	Firebase Firebase `mapstructure:"firebase"`
+	Internal Internal `mapstructure:"internal"`
 }
 
 // Prepare :
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/util"
+)
+
+// Executor : *sql.DB, *sql.Txの共通のメソッド
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DB : contextにトランザクションがある場合はそのトランザクションで実行する
+// repositoryはトランザクションの有無を意識せずにDBのメソッドを使う
+type DB struct {
+	psql *sql.DB
+}
+
+// NewDB :
+func NewDB(psql *sql.DB) *DB {
+	return &DB{psql: psql}
+}
+
+// Executor : util.SetDBTxされたトランザクション、ない場合は*sql.DB
+func (db *DB) Executor(ctx context.Context) Executor {
+	if tx := util.GetDBTx(ctx); tx != nil {
+		return tx
+	}
+	return db.psql
+}
+
+// ExecContext :
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.Executor(ctx).ExecContext(ctx, query, args...)
+}
+
+// QueryContext :
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.Executor(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext :
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.Executor(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// Transaction : fnをトランザクション内で実行し、エラーかpanicの場合はロールバックする
+// 既にトランザクション内の場合はそのトランザクションで実行する
+func (db *DB) Transaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if util.GetDBTx(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, err := db.psql.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(errof.ErrDatabase, err.Error())
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				err = errors.Wrapf(err, "failed to rollback: %s", rerr)
+			}
+			return
+		}
+		if cerr := tx.Commit(); cerr != nil {
+			err = errors.Wrap(errof.ErrDatabase, cerr.Error())
+		}
+	}()
+	return fn(util.SetDBTx(ctx, tx))
+}
+
+// Ping : readinessの確認用
+func (db *DB) Ping(ctx context.Context) error {
+	if err := db.psql.PingContext(ctx); err != nil {
+		return errors.Wrap(errof.ErrDatabase, err.Error())
+	}
+	return nil
+}
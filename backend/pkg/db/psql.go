@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/inconshreveable/log15"
+
+	// postgres driver
+	_ "github.com/lib/pq"
+)
+
+const (
+	defaultMaxOpenConns    = 10
+	defaultMaxIdleConns    = 5
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnectRetries  = 5
+	defaultConnectBackoff  = time.Second
+	maxConnectBackoff      = 30 * time.Second
+	pingTimeout            = 5 * time.Second
+)
+
+// NewPSQL : コネクションプールを作り、接続できるまで起動時にリトライする
+// applicationNameはpg_stat_activityで接続元を見分けるために使う
+// c.Pseudoの場合は起動時に接続を確認しない
+func NewPSQL(c config.Postgres, applicationName string) (*sql.DB, func(), error) {
+	psql, err := sql.Open("postgres", dataSourceName(c, applicationName))
+	if err != nil {
+		return nil, nil, errors.Wrap(errof.ErrDatabase, err.Error())
+	}
+	configurePool(psql, c)
+
+	cleanup := func() {
+		if err := psql.Close(); err != nil {
+			log15.Error("Failed to close database", "application_name", applicationName, "err", err)
+		}
+	}
+	if c.Pseudo {
+		return psql, cleanup, nil
+	}
+	if err := pingWithRetry(psql, c); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return psql, cleanup, nil
+}
+
+// dataSourceName : 値は'で囲み、'と\はエスケープする
+func dataSourceName(c config.Postgres, applicationName string) string {
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", c.Port},
+		{"user", c.User},
+		{"password", c.Pass},
+		{"dbname", c.DBName},
+		{"sslmode", c.Sslmode},
+		{"application_name", applicationName},
+	}
+	pairs := make([]string, 0, len(params))
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		pairs = append(pairs, fmt.Sprintf("%s='%s'", p.key, quote.Replace(p.value)))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// configurePool : 0の設定はデフォルト値、負の値はdatabase/sqlにそのまま渡す
+func configurePool(psql *sql.DB, c config.Postgres) {
+	maxOpenConns := c.MaxOpenConns
+	if maxOpenConns == 0 {
+		maxOpenConns = defaultMaxOpenConns
+	}
+	maxIdleConns := c.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	connMaxLifetime := c.ConnMaxLifetime
+	if connMaxLifetime == 0 {
+		connMaxLifetime = defaultConnMaxLifetime
+	}
+	psql.SetMaxOpenConns(maxOpenConns)
+	psql.SetMaxIdleConns(maxIdleConns)
+	psql.SetConnMaxLifetime(connMaxLifetime)
+	psql.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// pingWithRetry : 失敗する毎に待ち時間を倍にしてリトライする
+func pingWithRetry(psql *sql.DB, c config.Postgres) error {
+	retries := c.ConnectRetries
+	if retries == 0 {
+		retries = defaultConnectRetries
+	}
+	backoff := c.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = psql.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if retries <= attempt {
+			break
+		}
+		log15.Warn("Failed to connect database, retrying", "attempt", attempt+1, "backoff", backoff, "err", err)
+		time.Sleep(backoff)
+		if backoff *= 2; maxConnectBackoff < backoff {
+			backoff = maxConnectBackoff
+		}
+	}
+	return errors.Wrapf(errof.ErrDatabase, "failed to connect database host: %s, dbname: %s, err: %s", c.Host, c.DBName, err)
+}
@@ -49,8 +49,15 @@ var ErrCodeNames = map[UserErr]string{
 	ErrTooLongParameter: "パラメータが長すぎます",
 	ErrHTTP:             "HTTPでエラーが発生しました",
 	ErrExpired:          "トークンの有効期限が切れています",
+	ErrRequestTooLarge:  "リクエストが大きすぎます",
+	ErrBatchTooLarge:    "バッチのリクエスト数が多すぎます",
+	ErrNestingTooDeep:   "JSONの階層が深すぎます",
+	ErrPermissionDenied: "権限がありません",
+	ErrTimeout:          "処理がタイムアウトしました",
+	ErrRateLimited:      "リクエストが多すぎます",
 
-	ErrNoOrg: "オーガニゼーションが見つかりません",
+	ErrNoOrg:        "オーガニゼーションが見つかりません",
+	ErrNotOrgMember: "オーガニゼーションに所属していません",
 }
 
 // Error 定義
@@ -73,6 +80,13 @@ var (
 	ErrTooLongParameter UserErr = "ErrTooLongParameter"
 	ErrHTTP             UserErr = "ErrHTTP"
 	ErrExpired          UserErr = "ErrExpired"
+	ErrRequestTooLarge  UserErr = "ErrRequestTooLarge"
+	ErrBatchTooLarge    UserErr = "ErrBatchTooLarge"
+	ErrNestingTooDeep   UserErr = "ErrNestingTooDeep"
+	ErrPermissionDenied UserErr = "ErrPermissionDenied"
+	ErrTimeout          UserErr = "ErrTimeout"
+	ErrRateLimited      UserErr = "ErrRateLimited"
 
-	ErrNoOrg UserErr = "ErrNoOrg"
+	ErrNoOrg        UserErr = "ErrNoOrg"
+	ErrNotOrgMember UserErr = "ErrNotOrgMember"
 )
@@ -0,0 +1,115 @@
+package errof
+
+import (
+	"golang.org/x/text/language"
+)
+
+// Lang : メッセージの言語
+type Lang string
+
+// Lang 定義
+const (
+	LangJa Lang = "ja"
+	LangEn Lang = "en"
+
+	// DefaultLang : Accept-Languageに対応する言語がない場合の言語
+	DefaultLang = LangJa
+)
+
+// Messages : 1つの言語のメッセージ
+type Messages struct {
+	User       map[UserErr]string
+	Internal   map[InternalErr]string
+	Validation map[string]string
+}
+
+// Catalog : 言語毎のメッセージ、メッセージがない場合はDefaultLangのものを使う
+var Catalog = map[Lang]Messages{
+	LangJa: {User: ErrCodeNames, Internal: InternalErrCodeNames, Validation: ValidationMessages},
+	LangEn: {User: EnErrCodeNames, Internal: EnInternalErrCodeNames, Validation: EnValidationMessages},
+}
+
+var (
+	supportedLangs = []Lang{LangJa, LangEn}
+	langMatcher    = language.NewMatcher([]language.Tag{language.Japanese, language.English})
+)
+
+// EnInternalErrCodeNames :
+var EnInternalErrCodeNames = map[InternalErr]string{
+	ErrInternal: "An internal error occurred",
+	ErrServer:   "A server error occurred",
+	ErrFirebase: "An error occurred in the authentication system",
+	ErrDatabase: "A database inconsistency occurred",
+}
+
+// EnErrCodeNames :
+var EnErrCodeNames = map[UserErr]string{
+	ErrAuthentication:   "Authentication failed",
+	ErrInvalidMethod:    "Invalid method name",
+	ErrInvalidParams:    "Invalid parameters",
+	ErrMethodNotFound:   "Method not found",
+	ErrParse:            "Failed to parse parameters",
+	ErrInvalidRequest:   "Invalid request",
+	ErrParameter:        "Malformed parameter",
+	ErrTooLongParameter: "Parameter is too long",
+	ErrHTTP:             "An HTTP error occurred",
+	ErrExpired:          "The token has expired",
+	ErrRequestTooLarge:  "The request is too large",
+	ErrBatchTooLarge:    "The batch has too many requests",
+	ErrNestingTooDeep:   "The JSON is nested too deeply",
+	ErrPermissionDenied: "Permission denied",
+	ErrTimeout:          "The request timed out",
+	ErrRateLimited:      "Too many requests",
+
+	ErrNoOrg:        "Organization not found",
+	ErrNotOrgMember: "You are not a member of the organization",
+}
+
+// MatchLang : Accept-Languageヘッダから対応する言語を選ぶ
+func MatchLang(acceptLanguage string) Lang {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLang
+	}
+	_, i, confidence := langMatcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLang
+	}
+	return supportedLangs[i]
+}
+
+// Localize : errのlangでのメッセージを返す、errofのエラーでない場合はerr.Error()
+func Localize(err error, lang Lang) string {
+	messages, ok := Catalog[lang]
+	if !ok {
+		messages = Catalog[DefaultLang]
+	}
+	switch e := err.(type) {
+	case UserErr:
+		if msg, ok := messages.User[e]; ok {
+			return msg
+		}
+	case InternalErr:
+		if msg, ok := messages.Internal[e]; ok {
+			return msg
+		}
+	}
+	return err.Error()
+}
+
+// FromMessage : いずれかの言語のメッセージからerrofのエラーを返す
+func FromMessage(msg string) (error, bool) {
+	for _, messages := range Catalog {
+		for code, m := range messages.User {
+			if m == msg {
+				return code, true
+			}
+		}
+		for code, m := range messages.Internal {
+			if m == msg {
+				return code, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,57 @@
+package errof
+
+import "fmt"
+
+// ValidationMessages : validateタグ毎のメッセージ、%sにはタグのパラメータが入る
+var ValidationMessages = map[string]string{
+	"required": "必須項目です",
+	"email":    "メールアドレスの形式が正しくありません",
+	"url":      "URLの形式が正しくありません",
+	"uuid":     "UUIDの形式が正しくありません",
+	"min":      "%s以上で入力してください",
+	"max":      "%s以下で入力してください",
+	"gte":      "%s以上で入力してください",
+	"lte":      "%s以下で入力してください",
+	"gt":       "%sより大きい値を入力してください",
+	"lt":       "%sより小さい値を入力してください",
+	"len":      "長さは%sにしてください",
+	"oneof":    "%sのいずれかを入力してください",
+}
+
+// EnValidationMessages :
+var EnValidationMessages = map[string]string{
+	"required": "This field is required",
+	"email":    "Must be a valid email address",
+	"url":      "Must be a valid URL",
+	"uuid":     "Must be a valid UUID",
+	"min":      "Must be at least %s",
+	"max":      "Must be at most %s",
+	"gte":      "Must be at least %s",
+	"lte":      "Must be at most %s",
+	"gt":       "Must be greater than %s",
+	"lt":       "Must be less than %s",
+	"len":      "Length must be %s",
+	"oneof":    "Must be one of %s",
+}
+
+// defaultValidationMessages : タグに対するメッセージがない場合のメッセージ
+var defaultValidationMessages = map[Lang]string{
+	LangJa: "入力値が不正です",
+	LangEn: "Invalid value",
+}
+
+// ValidationMessage :
+func ValidationMessage(tag, param string, lang Lang) string {
+	messages, ok := Catalog[lang]
+	if !ok {
+		lang, messages = DefaultLang, Catalog[DefaultLang]
+	}
+	msg, ok := messages.Validation[tag]
+	if !ok {
+		return defaultValidationMessages[lang]
+	}
+	if param == "" {
+		return msg
+	}
+	return fmt.Sprintf(msg, param)
+}
@@ -0,0 +1,237 @@
+package firebase
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/util"
+)
+
+const (
+	// defaultKeysURL : Firebase Authの公開鍵 (JWKS)
+	defaultKeysURL = "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com"
+	// defaultKeysMaxAge : Cache-Controlにmax-ageがない場合のキャッシュ期間
+	defaultKeysMaxAge = time.Hour
+	// minRefreshInterval : 未知のkidで鍵を取り直す間隔の下限
+	minRefreshInterval = time.Minute
+	fetchTimeout       = 10 * time.Second
+	// minFetchBackoff, maxFetchBackoff : 取得に失敗した後、取り直すまでの間隔 (失敗する毎に倍にする)
+	minFetchBackoff = time.Second
+	maxFetchBackoff = time.Minute
+)
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// keySource : 公開鍵をCache-Controlのmax-ageの間キャッシュする
+// 取得はロックの外で行い、同時に取り直す場合は1つの取得を待ち合わせる
+type keySource struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+	// inflight : 取得中の場合、取得が終わると閉じられる
+	inflight chan struct{}
+	// err, retryAt : 取得に失敗した場合、retryAtまでは取り直さずにerrを返す
+	err     error
+	retryAt time.Time
+	backoff time.Duration
+}
+
+func newKeySource(url string) *keySource {
+	if url == "" {
+		url = defaultKeysURL
+	}
+	return &keySource{
+		url:    url,
+		client: &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+// key : kidの公開鍵を返す、キャッシュが切れているかkidが未知の場合は取り直す
+func (s *keySource) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	now := util.TimeNowFunc()
+	key, ok := s.keys[kid]
+	fresh := s.keys != nil && now.Before(s.expiresAt)
+	recent := now.Sub(s.fetchedAt) < minRefreshInterval
+	s.mu.Unlock()
+	if ok && fresh {
+		return key, nil
+	}
+	if !ok && fresh && recent {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "unknown kid: %s", kid)
+	}
+
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	key, ok = s.keys[kid]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "unknown kid: %s", kid)
+	}
+	return key, nil
+}
+
+// ready : キャッシュが切れている場合は取り直す
+func (s *keySource) ready(ctx context.Context) error {
+	s.mu.Lock()
+	fresh := 0 < len(s.keys) && util.TimeNowFunc().Before(s.expiresAt)
+	s.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) == 0 {
+		return errors.Wrap(errof.ErrFirebase, "no public keys")
+	}
+	return nil
+}
+
+// load : 取得中のものがあればそれを待ち、なければ取得を始める
+// 失敗した後はretryAtまで取り直さず、同じエラーを返す
+func (s *keySource) load(ctx context.Context) error {
+	s.mu.Lock()
+	if util.TimeNowFunc().Before(s.retryAt) {
+		err := s.err
+		s.mu.Unlock()
+		return err
+	}
+	if s.inflight == nil {
+		s.inflight = make(chan struct{})
+		go s.refresh(s.inflight)
+	}
+	done := s.inflight
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.Wrap(errof.ErrFirebase, ctx.Err().Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// refresh : 複数の呼び出し元で共有するため、呼び出し元のctxではなくfetchTimeoutで打ち切る
+func (s *keySource) refresh(done chan struct{}) {
+	keys, ttl, err := s.fetch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := util.TimeNowFunc()
+	if err != nil {
+		s.backoff *= 2
+		if s.backoff < minFetchBackoff {
+			s.backoff = minFetchBackoff
+		}
+		if maxFetchBackoff < s.backoff {
+			s.backoff = maxFetchBackoff
+		}
+		s.err = err
+		s.retryAt = now.Add(s.backoff)
+	} else {
+		s.keys = keys
+		s.fetchedAt = now
+		s.expiresAt = now.Add(ttl)
+		s.err = nil
+		s.retryAt = time.Time{}
+		s.backoff = 0
+	}
+	s.inflight = nil
+	close(done)
+}
+
+// fetch : 公開鍵とキャッシュする期間を返す
+func (s *keySource) fetch() (map[string]*rsa.PublicKey, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, 0, errors.Wrap(errof.ErrFirebase, err.Error())
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(errof.ErrFirebase, err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, errors.Wrapf(errof.ErrFirebase, "failed to fetch public keys, status: %d", res.StatusCode)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, 0, errors.Wrap(errof.ErrFirebase, err.Error())
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || k.Kid == "" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, 0, errors.Wrapf(errof.ErrFirebase, "invalid public key kid: %s, err: %s", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, maxAge(res.Header.Get("Cache-Control")), nil
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// maxAge : Cache-Controlのmax-age、ない場合はdefaultKeysMaxAge
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err != nil || seconds <= 0 {
+			break
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultKeysMaxAge
+}
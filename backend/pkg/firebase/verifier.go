@@ -0,0 +1,203 @@
+package firebase
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/util"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	issuerPrefix = "https://securetoken.google.com/"
+	// clockSkew : iat, auth_timeでサーバ間の時刻のずれを許容する幅
+	clockSkew = 5 * time.Minute
+)
+
+// Token : 検証済みのIDトークン
+type Token struct {
+	Credential jsonrpc.Credential
+	// Claims : カスタムクレームを含む全てのクレーム
+	Claims map[string]interface{}
+	// ExpiresAt : expのクレーム、pseudoVerifierの場合はゼロ値
+	ExpiresAt time.Time
+}
+
+// Verifier : FirebaseのIDトークンを検証する
+type Verifier interface {
+	Verify(ctx context.Context, idToken string) (*Token, error)
+	// Ready : 公開鍵を取得できるか (readinessの確認用)
+	Ready(ctx context.Context) error
+}
+
+// NewVerifier : c.Pseudoの場合は署名を検証せず、トークンをそのままUIDとして扱う (ローカル開発用)
+func NewVerifier(c config.Firebase) Verifier {
+	if c.Pseudo {
+		return pseudoVerifier{}
+	}
+	return &verifier{
+		projectID: c.ProjectID,
+		keys:      newKeySource(c.KeysURL),
+	}
+}
+
+type verifier struct {
+	projectID string
+	keys      *keySource
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Aud           string      `json:"aud"`
+	Iss           string      `json:"iss"`
+	Sub           string      `json:"sub"`
+	Exp           int64       `json:"exp"`
+	Iat           int64       `json:"iat"`
+	AuthTime      int64       `json:"auth_time"`
+	Email         string      `json:"email"`
+	EmailVerified bool        `json:"email_verified"`
+	Picture       null.String `json:"picture"`
+	Name          null.String `json:"name"`
+}
+
+// Verify : https://firebase.google.com/docs/auth/admin/verify-id-tokens#verify_id_tokens_using_a_third-party_jwt_library
+func (v *verifier) Verify(ctx context.Context, idToken string) (*Token, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.Wrap(errof.ErrAuthentication, "malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "invalid header: %s", err)
+	}
+	if h.Alg != "RS256" {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "unexpected alg: %s", h.Alg)
+	}
+	if h.Kid == "" {
+		return nil, errors.Wrap(errof.ErrAuthentication, "no kid")
+	}
+
+	key, err := v.keys.key(ctx, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "invalid signature: %s", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "invalid signature: %s", err)
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "invalid claims: %s", err)
+	}
+	if err := v.verifyClaims(c); err != nil {
+		return nil, err
+	}
+	all := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &all); err != nil {
+		return nil, errors.Wrapf(errof.ErrAuthentication, "invalid claims: %s", err)
+	}
+
+	return &Token{
+		Credential: jsonrpc.Credential{
+			UID:           c.Sub,
+			Email:         c.Email,
+			EmailVerified: c.EmailVerified,
+			Picture:       c.Picture,
+			Name:          c.Name,
+		},
+		Claims:    all,
+		ExpiresAt: time.Unix(c.Exp, 0),
+	}, nil
+}
+
+func (v *verifier) Ready(ctx context.Context) error {
+	return v.keys.ready(ctx)
+}
+
+func (v *verifier) verifyClaims(c claims) error {
+	now := util.TimeNowFunc()
+	if c.Aud != v.projectID {
+		return errors.Wrapf(errof.ErrAuthentication, "unexpected aud: %s", c.Aud)
+	}
+	if c.Iss != issuerPrefix+v.projectID {
+		return errors.Wrapf(errof.ErrAuthentication, "unexpected iss: %s", c.Iss)
+	}
+	if c.Sub == "" || 128 < len(c.Sub) {
+		return errors.Wrap(errof.ErrAuthentication, "invalid sub")
+	}
+	if !now.Before(time.Unix(c.Exp, 0)) {
+		return errors.Wrapf(errof.ErrExpired, "exp: %d", c.Exp)
+	}
+	if now.Add(clockSkew).Before(time.Unix(c.Iat, 0)) {
+		return errors.Wrapf(errof.ErrAuthentication, "issued in the future, iat: %d", c.Iat)
+	}
+	if now.Add(clockSkew).Before(time.Unix(c.AuthTime, 0)) {
+		return errors.Wrapf(errof.ErrAuthentication, "authenticated in the future, auth_time: %d", c.AuthTime)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// pseudoVerifier : 開発用、トークンをそのままUIDとする
+type pseudoVerifier struct{}
+
+func (pseudoVerifier) Ready(context.Context) error {
+	return nil
+}
+
+func (pseudoVerifier) Verify(_ context.Context, idToken string) (*Token, error) {
+	if idToken == "" {
+		return nil, errors.Wrap(errof.ErrAuthentication, "empty token")
+	}
+	return &Token{
+		Credential: jsonrpc.Credential{UID: idToken},
+		Claims:     map[string]interface{}{},
+	}, nil
+}
+
+// rolesClaim : ロールを入れるカスタムクレーム
+const rolesClaim = "roles"
+
+// Roles : カスタムクレームのroles、文字列1つの場合も受け付ける
+func (t *Token) Roles() []string {
+	switch v := t.Claims[rolesClaim].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, role := range v {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package firebase
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/errof"
+)
+
+const (
+	testProjectID = "test-project"
+	testKid       = "kid-1"
+)
+
+// testKeys : テスト用の鍵ペア、生成が遅いためテスト間で共有する
+var testKeys = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// jwksServer : testKeysの公開鍵をtestKidで返す、取得された回数を数える
+type jwksServer struct {
+	*httptest.Server
+	fetches int32
+	// status : 200以外の場合はエラーを返す
+	status int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.fetches, 1)
+		if status := int(atomic.LoadInt32(&s.status)); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		pub := testKeys.PublicKey
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_ = json.NewEncoder(w).Encode(jwks{Keys: []jwk{{
+			Kty: "RSA",
+			Kid: testKid,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestVerifier(s *jwksServer) Verifier {
+	return NewVerifier(config.Firebase{ProjectID: testProjectID, KeysURL: s.URL})
+}
+
+func validClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"aud":       testProjectID,
+		"iss":       issuerPrefix + testProjectID,
+		"sub":       "uid-1",
+		"exp":       now.Add(time.Hour).Unix(),
+		"iat":       now.Unix(),
+		"auth_time": now.Unix(),
+		"email":     "a@example.com",
+		"roles":     []string{"admin"},
+	}
+}
+
+func sign(t *testing.T, h header, claims map[string]interface{}) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signingInput := encode(h) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, testKeys, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	with := func(key string, value interface{}) map[string]interface{} {
+		c := validClaims()
+		c[key] = value
+		return c
+	}
+	valid := header{Alg: "RS256", Kid: testKid}
+	tests := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: func(t *testing.T) string { return sign(t, valid, validClaims()) },
+		},
+		{
+			name:    "wrong aud",
+			token:   func(t *testing.T) string { return sign(t, valid, with("aud", "other")) },
+			wantErr: errof.ErrAuthentication,
+		},
+		{
+			name:    "wrong iss",
+			token:   func(t *testing.T) string { return sign(t, valid, with("iss", issuerPrefix+"other")) },
+			wantErr: errof.ErrAuthentication,
+		},
+		{
+			name:    "empty sub",
+			token:   func(t *testing.T) string { return sign(t, valid, with("sub", "")) },
+			wantErr: errof.ErrAuthentication,
+		},
+		{
+			name:    "expired",
+			token:   func(t *testing.T) string { return sign(t, valid, with("exp", time.Now().Add(-time.Minute).Unix())) },
+			wantErr: errof.ErrExpired,
+		},
+		{
+			name:    "issued in the future",
+			token:   func(t *testing.T) string { return sign(t, valid, with("iat", time.Now().Add(time.Hour).Unix())) },
+			wantErr: errof.ErrAuthentication,
+		},
+		{
+			name:    "unknown kid",
+			token:   func(t *testing.T) string { return sign(t, header{Alg: "RS256", Kid: "other"}, validClaims()) },
+			wantErr: errof.ErrAuthentication,
+		},
+		{
+			name:    "unexpected alg",
+			token:   func(t *testing.T) string { return sign(t, header{Alg: "none", Kid: testKid}, validClaims()) },
+			wantErr: errof.ErrAuthentication,
+		},
+		{
+			name: "bad signature",
+			token: func(t *testing.T) string {
+				// 署名後にクレームを書き換える
+				parts := strings.Split(sign(t, valid, validClaims()), ".")
+				forged := strings.Split(sign(t, valid, with("sub", "uid-2")), ".")
+				return parts[0] + "." + forged[1] + "." + parts[2]
+			},
+			wantErr: errof.ErrAuthentication,
+		},
+		{
+			name:    "malformed",
+			token:   func(t *testing.T) string { return "a.b" },
+			wantErr: errof.ErrAuthentication,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestVerifier(newJWKSServer(t))
+			token, err := v.Verify(context.Background(), tt.token(t))
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if token.Credential.UID != "uid-1" || token.Credential.Email != "a@example.com" {
+				t.Errorf("Credential = %+v", token.Credential)
+			}
+			if roles := token.Roles(); len(roles) != 1 || roles[0] != "admin" {
+				t.Errorf("Roles() = %v, want [admin]", roles)
+			}
+			if !token.ExpiresAt.After(time.Now()) {
+				t.Errorf("ExpiresAt = %v", token.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestVerifyFetchesKeysOnce(t *testing.T) {
+	s := newJWKSServer(t)
+	v := newTestVerifier(s)
+	token := sign(t, header{Alg: "RS256", Kid: testKid}, validClaims())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(context.Background(), token); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches := atomic.LoadInt32(&s.fetches); fetches != 1 {
+		t.Errorf("fetches = %d, want 1", fetches)
+	}
+
+	// 未知のkidでもminRefreshIntervalの間は取り直さない
+	unknown := sign(t, header{Alg: "RS256", Kid: "other"}, validClaims())
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(context.Background(), unknown); errors.Cause(err) != errof.ErrAuthentication {
+			t.Fatalf("Verify() error = %v, want %v", err, errof.ErrAuthentication)
+		}
+	}
+	if fetches := atomic.LoadInt32(&s.fetches); fetches != 1 {
+		t.Errorf("fetches = %d, want 1", fetches)
+	}
+}
+
+func TestVerifyFetchFailureBackoff(t *testing.T) {
+	s := newJWKSServer(t)
+	atomic.StoreInt32(&s.status, http.StatusInternalServerError)
+	v := newTestVerifier(s)
+	token := sign(t, header{Alg: "RS256", Kid: testKid}, validClaims())
+
+	// 失敗した後はバックオフの間、取り直さずに同じエラーを返す
+	for i := 0; i < 5; i++ {
+		if _, err := v.Verify(context.Background(), token); errors.Cause(err) != errof.ErrFirebase {
+			t.Fatalf("Verify() error = %v, want %v", err, errof.ErrFirebase)
+		}
+	}
+	if fetches := atomic.LoadInt32(&s.fetches); fetches != 1 {
+		t.Errorf("fetches = %d, want 1", fetches)
+	}
+	if err := v.Ready(context.Background()); errors.Cause(err) != errof.ErrFirebase {
+		t.Errorf("Ready() error = %v, want %v", err, errof.ErrFirebase)
+	}
+
+	// バックオフの後は取り直す
+	atomic.StoreInt32(&s.status, http.StatusOK)
+	time.Sleep(minFetchBackoff)
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if err := v.Ready(context.Background()); err != nil {
+		t.Errorf("Ready() error = %v", err)
+	}
+}
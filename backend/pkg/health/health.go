@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/inconshreveable/log15"
+)
+
+const (
+	defaultTimeout = 3 * time.Second
+
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+// Check : 依存先が利用できない場合はエラーを返す
+type Check func(ctx context.Context) error
+
+// Checker : /healthz, /readyz を提供する
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+	// notReady : Shutdownが始まったら1にする
+	notReady int32
+}
+
+// Result : /readyz のレスポンス
+type Result struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult : 依存先毎の結果
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// NewChecker : timeoutは依存先毎の確認の上限、0以下はdefaultTimeout
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Checker{
+		timeout: timeout,
+		checks:  map[string]Check{},
+	}
+}
+
+// Register : 同じnameのCheckは上書きする
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// SetNotReady : 以降の/readyzは503を返す、http.Server.RegisterOnShutdownに渡す
+func (c *Checker) SetNotReady() {
+	atomic.StoreInt32(&c.notReady, 1)
+}
+
+// Ready : 登録された全てのCheckを並列に実行する
+func (c *Checker) Ready(ctx context.Context) Result {
+	if atomic.LoadInt32(&c.notReady) == 1 {
+		return Result{Status: statusUnavailable}
+	}
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	result := Result{Status: statusOK, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		result.Checks[name] = results[i]
+		if results[i].Status != statusOK {
+			result.Status = statusUnavailable
+		}
+	}
+	return result
+}
+
+// run : Checkがctxを無視してもtimeoutで打ち切る
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := CheckResult{
+		Status:     statusOK,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = statusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler : プロセスが応答できれば200を返す
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, http.StatusOK, Result{Status: statusOK})
+	})
+}
+
+// ReadinessHandler : 全てのCheckが成功した場合のみ200、それ以外は503を返す
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := c.Ready(r.Context())
+		status := http.StatusOK
+		if result.Status != statusOK {
+			status = http.StatusServiceUnavailable
+			log15.Warn("Not ready", "result", result)
+		}
+		writeResult(w, status, result)
+	})
+}
+
+func writeResult(w http.ResponseWriter, status int, result Result) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log15.Error("Failed to write health response", "err", err)
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+)
+
+// Batch : 複数の呼び出しを1つのリクエストで送信する
+type Batch struct {
+	client *Client
+	calls  []*BatchCall
+}
+
+// BatchCall : バッチ内の1つの呼び出し、Send後にErrとResultが埋まる
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+
+	notification bool
+	request      *jsonrpc.Request
+}
+
+// NewBatch :
+func (c *Client) NewBatch() *Batch {
+	return &Batch{client: c}
+}
+
+// Add : 呼び出しを追加する、resultはSend時にデコード先として使われる
+func (b *Batch) Add(method string, params, result interface{}) *BatchCall {
+	call := &BatchCall{Method: method, Params: params, Result: result}
+	b.calls = append(b.calls, call)
+	return call
+}
+
+// Notify : 通知を追加する
+func (b *Batch) Notify(method string, params interface{}) *BatchCall {
+	call := &BatchCall{Method: method, Params: params, notification: true}
+	b.calls = append(b.calls, call)
+	return call
+}
+
+// Send : バッチを送信し、レスポンスをidで各呼び出しに振り分ける
+// 返り値のエラーは送信自体の失敗で、各呼び出しのエラーはBatchCall.Errに入る
+func (b *Batch) Send(ctx context.Context) error {
+	return b.send(ctx, true)
+}
+
+func (b *Batch) send(ctx context.Context, batch bool) error {
+	if len(b.calls) == 0 {
+		return errors.Wrap(errof.ErrInvalidRequest, "empty batch")
+	}
+
+	requests := make([]*jsonrpc.Request, 0, len(b.calls))
+	calls := make(map[string]*BatchCall, len(b.calls))
+	for _, call := range b.calls {
+		r, err := b.client.newRequest(call.Method, call.Params, call.notification)
+		if err != nil {
+			return err
+		}
+		call.request = r
+		requests = append(requests, r)
+		if !call.notification {
+			calls[idKey(r.ID)] = call
+		}
+	}
+
+	notificationsOnly := len(calls) == 0
+	var requestErr error
+
+	var body interface{} = requests
+	if !batch && len(requests) == 1 {
+		body = requests[0]
+	}
+	raw, err := b.client.post(ctx, body)
+	if err != nil {
+		return err
+	}
+	returns, err := decodeReturns(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, ret := range returns {
+		var result json.RawMessage
+		r := &jsonrpc.Return{Result: &result}
+		if err := json.Unmarshal(ret, r); err != nil {
+			return errors.Wrap(errof.ErrParse, err.Error())
+		}
+
+		// idがnullのエラーはリクエスト全体に対するもの
+		if r.ID == nil {
+			if r.Error == nil {
+				continue
+			}
+			requestErr = newError(r.Error)
+			for _, call := range calls {
+				if call.Err == nil {
+					call.Err = newError(r.Error)
+				}
+			}
+			continue
+		}
+
+		call, ok := calls[idKey(r.ID)]
+		if !ok {
+			continue
+		}
+		delete(calls, idKey(r.ID))
+		if r.Error != nil {
+			call.Err = newError(r.Error)
+			continue
+		}
+		if call.Result != nil && len(result) != 0 {
+			if err := json.Unmarshal(result, call.Result); err != nil {
+				call.Err = errors.Wrap(errof.ErrParse, err.Error())
+			}
+		}
+	}
+
+	for id, call := range calls {
+		if call.Err == nil {
+			call.Err = errors.Wrapf(errof.ErrServer, "no response for id: %s", id)
+		}
+	}
+	// 通知のみの場合は、リクエスト全体のエラーを返り値で返す
+	if requestErr != nil && notificationsOnly {
+		return requestErr
+	}
+	return nil
+}
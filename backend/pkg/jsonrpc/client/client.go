@@ -0,0 +1,178 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+)
+
+// HeaderFunc : リクエスト毎にヘッダを付与する
+type HeaderFunc func(ctx context.Context, header http.Header) error
+
+// Option :
+type Option func(*Client)
+
+// Client : JSON-RPCのエンドポイントを呼び出すクライアント
+type Client struct {
+	endpoint    string
+	httpClient  *http.Client
+	headerFuncs []HeaderFunc
+	id          uint64
+}
+
+// NewClient :
+func NewClient(endpoint string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:   endpoint,
+		httpClient: &http.Client{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithHTTPClient :
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRoundTripper :
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient = &http.Client{Transport: rt}
+	}
+}
+
+// WithHeader :
+func WithHeader(key, value string) Option {
+	return WithHeaderFunc(func(ctx context.Context, header http.Header) error {
+		header.Set(key, value)
+		return nil
+	})
+}
+
+// WithHeaderFunc :
+func WithHeaderFunc(f HeaderFunc) Option {
+	return func(c *Client) {
+		c.headerFuncs = append(c.headerFuncs, f)
+	}
+}
+
+// WithAuthorization : Authorizationヘッダをリクエスト毎に取得して付与する
+func WithAuthorization(token func(ctx context.Context) (string, error)) Option {
+	return WithHeaderFunc(func(ctx context.Context, header http.Header) error {
+		t, err := token(ctx)
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", t)
+		return nil
+	})
+}
+
+// Call : methodを呼び出し、結果をresultにデコードする
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	b := c.NewBatch()
+	call := b.Add(method, params, result)
+	if err := b.send(ctx, false); err != nil {
+		return err
+	}
+	return call.Err
+}
+
+// Notify : methodを通知として呼び出す (レスポンスは返らない)
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	b := c.NewBatch()
+	b.Notify(method, params)
+	return b.send(ctx, false)
+}
+
+func (c *Client) nextID() uint64 {
+	return atomic.AddUint64(&c.id, 1)
+}
+
+func (c *Client) newRequest(method string, params interface{}, notification bool) (*jsonrpc.Request, error) {
+	r := &jsonrpc.Request{JSONRPC: jsonrpc.Version, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, errors.Wrap(errof.ErrParameter, err.Error())
+		}
+		r.Params = raw
+	}
+	if !notification {
+		r.ID = c.nextID()
+	}
+	return r, nil
+}
+
+// post : リクエストを送信し、レスポンスのJSONを返す (通知のみの場合はnil)
+func (c *Client) post(ctx context.Context, body interface{}) (json.RawMessage, error) {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.Wrap(errof.ErrParameter, err.Error())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return nil, errors.Wrap(errof.ErrHTTP, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, f := range c.headerFuncs {
+		if err := f(ctx, req.Header); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(errof.ErrHTTP, err.Error())
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	// 429はリクエスト全体のエラーをJSON-RPCのエラーとして返す
+	case http.StatusOK, http.StatusTooManyRequests:
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, errors.Wrapf(errof.ErrHTTP, "unexpected status: %d", res.StatusCode)
+	}
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(errof.ErrHTTP, err.Error())
+	}
+	return raw, nil
+}
+
+// decodeReturns : 単一・バッチどちらのレスポンスも[]json.RawMessageにする
+func decodeReturns(raw json.RawMessage) ([]json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if raw[0] != '[' {
+		return []json.RawMessage{raw}, nil
+	}
+	var returns []json.RawMessage
+	if err := json.Unmarshal(raw, &returns); err != nil {
+		return nil, errors.Wrap(errof.ErrParse, err.Error())
+	}
+	return returns, nil
+}
+
+// idKey : 数値のidはfloat64でデコードされるため文字列にして比較する
+func idKey(id interface{}) string {
+	return fmt.Sprint(id)
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+)
+
+// testServer : echoはparamsをそのまま返し、それ以外のメソッドはMethod not foundを返す
+// authorizationが空でない場合、Authorizationヘッダが一致しなければリクエスト全体をエラーにする
+// retryAfterが0でない場合、429とレート制限のエラーを返す
+type testServer struct {
+	authorization string
+	retryAfter    time.Duration
+	requests      int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	if 0 < s.retryAfter {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = jsonrpc.WriteResponses(w, false, &jsonrpc.Return{Error: jsonrpc.ErrRateLimited(&jsonrpc.RateLimitError{RetryAfter: s.retryAfter})})
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	requests, batch, err := jsonrpc.ParseBytes(body, jsonrpc.Limits{})
+	if err != nil {
+		_ = jsonrpc.WriteResponses(w, false, &jsonrpc.Return{Error: jsonrpc.ErrParse()})
+		return
+	}
+	if s.authorization != "" && r.Header.Get("Authorization") != s.authorization {
+		_ = jsonrpc.WriteResponses(w, false, &jsonrpc.Return{Error: jsonrpc.ErrInternal()})
+		return
+	}
+	returns := make([]*jsonrpc.Return, 0, len(requests))
+	for _, request := range requests {
+		ret := &jsonrpc.Return{ID: request.ID, Notification: request.IsNotification()}
+		if request.Method == "echo" {
+			ret.Result = request.Params
+		} else {
+			ret.Error = jsonrpc.ErrMethodNotFound()
+		}
+		returns = append(returns, ret)
+	}
+	_ = jsonrpc.WriteResponses(w, batch, returns...)
+}
+
+func newTestClient(s *testServer, opts ...Option) *Client {
+	return NewClient("http://rpc/", append([]Option{WithRoundTripper(HandlerTransport{Handler: s})}, opts...)...)
+}
+
+func TestCall(t *testing.T) {
+	type params struct {
+		A string `json:"a"`
+		B int    `json:"b"`
+	}
+	tests := []struct {
+		name      string
+		method    string
+		wantCause error
+	}{
+		{name: "result", method: "echo"},
+		{name: "error", method: "nope", wantCause: errof.ErrMethodNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(&testServer{})
+			var got params
+			err := c.Call(context.Background(), tt.method, params{"x", 1}, &got)
+			if errors.Cause(err) != tt.wantCause {
+				t.Fatalf("Call() error = %v, want %v", err, tt.wantCause)
+			}
+			if tt.wantCause == nil && got != (params{"x", 1}) {
+				t.Errorf("result = %+v", got)
+			}
+			var rpcErr *Error
+			if tt.wantCause != nil && (!errors.As(err, &rpcErr) || rpcErr.Code != jsonrpc.ErrorCodeMethodNotFound) {
+				t.Errorf("Call() error = %#v, want *Error with code %d", err, jsonrpc.ErrorCodeMethodNotFound)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	s := &testServer{}
+	if err := newTestClient(s).Notify(context.Background(), "echo", nil); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if s.requests != 1 {
+		t.Errorf("requests = %d, want 1", s.requests)
+	}
+}
+
+func TestBatchSend(t *testing.T) {
+	c := newTestClient(&testServer{})
+	b := c.NewBatch()
+	var r1, r2 int
+	c1 := b.Add("echo", 1, &r1)
+	b.Notify("echo", 2)
+	c2 := b.Add("nope", nil, nil)
+	c3 := b.Add("echo", 3, &r2)
+	if err := b.Send(context.Background()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if c1.Err != nil || r1 != 1 {
+		t.Errorf("c1 = %d, %v", r1, c1.Err)
+	}
+	if errors.Cause(c2.Err) != errof.ErrMethodNotFound {
+		t.Errorf("c2.Err = %v, want %v", c2.Err, errof.ErrMethodNotFound)
+	}
+	if c3.Err != nil || r2 != 3 {
+		t.Errorf("c3 = %d, %v", r2, c3.Err)
+	}
+}
+
+func TestBatchSendSingleResponseArray(t *testing.T) {
+	// 通知を含むバッチで、レスポンスが1つでも配列で返る場合
+	c := newTestClient(&testServer{})
+	b := c.NewBatch()
+	var r int
+	b.Notify("echo", 1)
+	call := b.Add("echo", 2, &r)
+	if err := b.Send(context.Background()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if call.Err != nil || r != 2 {
+		t.Errorf("call = %d, %v", r, call.Err)
+	}
+}
+
+func TestBatchSendRequestError(t *testing.T) {
+	// idがnullのエラーはバッチの全ての呼び出しのエラーになる
+	c := newTestClient(&testServer{authorization: "token"})
+	b := c.NewBatch()
+	c1 := b.Add("echo", 1, nil)
+	c2 := b.Add("echo", 2, nil)
+	if err := b.Send(context.Background()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	for i, call := range []*BatchCall{c1, c2} {
+		if errors.Cause(call.Err) != errof.ErrInternal {
+			t.Errorf("calls[%d].Err = %v, want %v", i, call.Err, errof.ErrInternal)
+		}
+	}
+}
+
+func TestWithAuthorization(t *testing.T) {
+	s := &testServer{authorization: "token"}
+	c := newTestClient(s, WithAuthorization(func(ctx context.Context) (string, error) {
+		return "token", nil
+	}))
+	var got string
+	if err := c.Call(context.Background(), "echo", "a", &got); err != nil || got != "a" {
+		t.Fatalf("Call() = %q, %v", got, err)
+	}
+
+	tokenErr := errors.New("no token")
+	c = newTestClient(s, WithAuthorization(func(ctx context.Context) (string, error) {
+		return "", tokenErr
+	}))
+	if err := c.Call(context.Background(), "echo", "a", &got); err != tokenErr {
+		t.Errorf("Call() error = %v, want %v", err, tokenErr)
+	}
+}
+
+func TestDecodeReturns(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{name: "empty", raw: "", want: 0},
+		{name: "single", raw: `{"jsonrpc":"2.0","id":1,"result":1}`, want: 1},
+		{name: "batch", raw: `[{"jsonrpc":"2.0","id":1},{"jsonrpc":"2.0","id":2}]`, want: 2},
+		{name: "invalid batch", raw: `[{"jsonrpc":"2.0","id":1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeReturns(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeReturns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("len(decodeReturns()) = %d, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	s := &testServer{retryAfter: 3 * time.Second}
+	c := newTestClient(s)
+	check := func(t *testing.T, err error) {
+		t.Helper()
+		if errors.Cause(err) != errof.ErrRateLimited {
+			t.Fatalf("error = %v, want %v", err, errof.ErrRateLimited)
+		}
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			t.Fatalf("error = %#v, want *Error", err)
+		}
+		if retryAfter, ok := rpcErr.RetryAfter(); !ok || retryAfter != 3*time.Second {
+			t.Errorf("RetryAfter() = %s, %v, want 3s", retryAfter, ok)
+		}
+	}
+
+	t.Run("call", func(t *testing.T) {
+		check(t, c.Call(context.Background(), "echo", 1, nil))
+	})
+	t.Run("notify", func(t *testing.T) {
+		check(t, c.Notify(context.Background(), "echo", 1))
+	})
+	t.Run("batch", func(t *testing.T) {
+		b := c.NewBatch()
+		call := b.Add("echo", 1, nil)
+		b.Notify("echo", 2)
+		if err := b.Send(context.Background()); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		check(t, call.Err)
+	})
+}
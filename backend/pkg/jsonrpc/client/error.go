@@ -0,0 +1,85 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+)
+
+// Error : サーバから返されたJSON-RPCのエラー
+// errors.Causeで対応するerrofのエラーを取り出せる
+type Error struct {
+	Code    jsonrpc.ErrorCode
+	Message string
+	Data    interface{}
+	cause   error
+}
+
+func newError(e *jsonrpc.Error) *Error {
+	return &Error{
+		Code:    e.Code,
+		Message: e.Message,
+		Data:    e.Data,
+		cause:   causeOf(e),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: code: %d, message: %s", e.Code, e.Message)
+}
+
+// RetryAfter : レート制限された場合、再試行できるまでの時間 (jsonrpc.RateLimitData)
+func (e *Error) RetryAfter() (time.Duration, bool) {
+	if e.Code != jsonrpc.ErrorCodeRateLimited {
+		return 0, false
+	}
+	data, ok := e.Data.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	seconds, ok := data["retry_after"].(float64)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// Cause :
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// Unwrap :
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// causeOf : メッセージとエラーコードからerrofのエラーに戻す
+func causeOf(e *jsonrpc.Error) error {
+	// ErrServerや上限超過などはメッセージにエラーの内容が入っている
+	if cause, ok := errof.FromMessage(e.Message); ok {
+		return cause
+	}
+
+	switch e.Code {
+	case jsonrpc.ErrorCodeParse:
+		return errof.ErrParse
+	case jsonrpc.ErrorCodeInvalidRequest:
+		return errof.ErrInvalidRequest
+	case jsonrpc.ErrorCodeMethodNotFound:
+		return errof.ErrMethodNotFound
+	case jsonrpc.ErrorCodeInvalidParams:
+		return errof.ErrInvalidParams
+	case jsonrpc.ErrorCodeInternal:
+		return errof.ErrInternal
+	case jsonrpc.ErrorCodePermissionDenied:
+		return errof.ErrPermissionDenied
+	case jsonrpc.ErrorCodeTimeout:
+		return errof.ErrTimeout
+	case jsonrpc.ErrorCodeRateLimited:
+		return errof.ErrRateLimited
+	}
+	return errof.ErrServer
+}
@@ -0,0 +1,19 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// HandlerTransport : http.Handlerを直接呼び出すRoundTripper
+// サーバを立てずにハンドラをテストする時に使う
+type HandlerTransport struct {
+	Handler http.Handler
+}
+
+// RoundTrip :
+func (t HandlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.Handler.ServeHTTP(w, r)
+	return w.Result(), nil
+}
@@ -1,7 +1,10 @@
 package jsonrpc
 
 import (
-	"github.com/omeroid/wdc/backend/pkg/errof"
+	"fmt"
+	"time"
+
+	"github.com/httptest/backend/pkg/errof"
 )
 
 const (
@@ -17,6 +20,12 @@ const (
 	ErrorCodeInternal ErrorCode = -32603
 	// ErrorCodeServer is server error code.
 	ErrorCodeServer ErrorCode = -32000
+	// ErrorCodePermissionDenied is permission denied error code.
+	ErrorCodePermissionDenied ErrorCode = -32001
+	// ErrorCodeTimeout is timeout error code.
+	ErrorCodeTimeout ErrorCode = -32002
+	// ErrorCodeRateLimited is rate limited error code.
+	ErrorCodeRateLimited ErrorCode = -32003
 )
 
 type (
@@ -28,14 +37,106 @@ type (
 		Code    ErrorCode   `json:"code"`
 		Message string      `json:"message"`
 		Data    interface{} `json:"data,omitempty"`
+
+		// err is the errof error used to localize the message.
+		err error
+	}
+
+	// A LimitError is returned when a request exceeds one of the Limits.
+	LimitError struct {
+		Err   errof.UserErr
+		Name  string
+		Limit int64
+	}
+
+	// A RateLimitError is returned when the caller exceeds the rate limit.
+	RateLimitError struct {
+		RetryAfter time.Duration
+	}
+
+	// An InvalidParamsError has the validation error of each field.
+	InvalidParamsError struct {
+		Fields []FieldError
+	}
+
+	// A FieldError is a validation error of a params field.
+	FieldError struct {
+		Field   string `json:"field"`
+		Tag     string `json:"tag"`
+		Param   string `json:"param,omitempty"`
+		Message string `json:"message"`
+	}
+
+	// RateLimitData is the data of the error for a RateLimitError.
+	RateLimitData struct {
+		// RetryAfter is in seconds, as in the Retry-After header.
+		RetryAfter int64 `json:"retry_after"`
+	}
+
+	// LimitData is the data of the error for a LimitError.
+	LimitData struct {
+		Name  string `json:"name"`
+		Limit int64  `json:"limit"`
 	}
 )
 
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s: %d", e.Err.Error(), e.Name, e.Limit)
+}
+
+// Cause returns the errof error.
+func (e *LimitError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the errof error.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", errof.ErrRateLimited.Error(), e.RetryAfter)
+}
+
+// Cause returns errof.ErrRateLimited.
+func (e *RateLimitError) Cause() error {
+	return errof.ErrRateLimited
+}
+
+// Unwrap returns errof.ErrRateLimited.
+func (e *RateLimitError) Unwrap() error {
+	return errof.ErrRateLimited
+}
+
+// RetryAfterSeconds rounds RetryAfter up to seconds, at least 1.
+func (e *RateLimitError) RetryAfterSeconds() int64 {
+	seconds := int64((e.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+func (e *InvalidParamsError) Error() string {
+	return fmt.Sprintf("%s: %+v", errof.ErrInvalidParams.Error(), e.Fields)
+}
+
+// Cause returns errof.ErrInvalidParams.
+func (e *InvalidParamsError) Cause() error {
+	return errof.ErrInvalidParams
+}
+
+// Unwrap returns errof.ErrInvalidParams.
+func (e *InvalidParamsError) Unwrap() error {
+	return errof.ErrInvalidParams
+}
+
 // ErrParse returns parse error.
 func ErrParse() *Error {
 	return &Error{
 		Code:    ErrorCodeParse,
 		Message: errof.ErrParse.Error(),
+		err:     errof.ErrParse,
 	}
 }
 
@@ -44,6 +145,7 @@ func ErrInvalidRequest() *Error {
 	return &Error{
 		Code:    ErrorCodeInvalidRequest,
 		Message: errof.ErrInvalidRequest.Error(),
+		err:     errof.ErrInvalidRequest,
 	}
 }
 
@@ -52,15 +154,22 @@ func ErrMethodNotFound() *Error {
 	return &Error{
 		Code:    ErrorCodeMethodNotFound,
 		Message: errof.ErrMethodNotFound.Error(),
+		err:     errof.ErrMethodNotFound,
 	}
 }
 
 // ErrInvalidParams returns invalid params error.
-func ErrInvalidParams() *Error {
-	return &Error{
+// The field errors are set to the data if given.
+func ErrInvalidParams(fields ...FieldError) *Error {
+	e := &Error{
 		Code:    ErrorCodeInvalidParams,
 		Message: errof.ErrInvalidParams.Error(),
+		err:     errof.ErrInvalidParams,
+	}
+	if 0 < len(fields) {
+		e.Data = fields
 	}
+	return e
 }
 
 // ErrTooLongParameter returns too long parameter error.
@@ -68,6 +177,54 @@ func ErrTooLongParameter() *Error {
 	return &Error{
 		Code:    ErrorCodeInvalidParams,
 		Message: errof.ErrTooLongParameter.Error(),
+		err:     errof.ErrTooLongParameter,
+	}
+}
+
+// ErrLimitExceeded returns invalid request error with the exceeded limit.
+func ErrLimitExceeded(e *LimitError) *Error {
+	return &Error{
+		Code:    ErrorCodeInvalidRequest,
+		Message: e.Err.Error(),
+		Data:    LimitData{Name: e.Name, Limit: e.Limit},
+		err:     e.Err,
+	}
+}
+
+// ErrPermissionDenied returns permission denied error.
+func ErrPermissionDenied() *Error {
+	return &Error{
+		Code:    ErrorCodePermissionDenied,
+		Message: errof.ErrPermissionDenied.Error(),
+		err:     errof.ErrPermissionDenied,
+	}
+}
+
+// ErrNotOrgMember returns permission denied error for a user outside the organization.
+func ErrNotOrgMember() *Error {
+	return &Error{
+		Code:    ErrorCodePermissionDenied,
+		Message: errof.ErrNotOrgMember.Error(),
+		err:     errof.ErrNotOrgMember,
+	}
+}
+
+// ErrTimeout returns timeout error.
+func ErrTimeout() *Error {
+	return &Error{
+		Code:    ErrorCodeTimeout,
+		Message: errof.ErrTimeout.Error(),
+		err:     errof.ErrTimeout,
+	}
+}
+
+// ErrRateLimited returns rate limited error with the seconds to retry after.
+func ErrRateLimited(e *RateLimitError) *Error {
+	return &Error{
+		Code:    ErrorCodeRateLimited,
+		Message: errof.ErrRateLimited.Error(),
+		Data:    RateLimitData{RetryAfter: e.RetryAfterSeconds()},
+		err:     errof.ErrRateLimited,
 	}
 }
 
@@ -76,6 +233,7 @@ func ErrInternal() *Error {
 	return &Error{
 		Code:    ErrorCodeInternal,
 		Message: errof.ErrInternal.Error(),
+		err:     errof.ErrInternal,
 	}
 }
 
@@ -84,5 +242,19 @@ func ErrServer(err error) *Error {
 	return &Error{
 		Code:    ErrorCodeServer,
 		Message: err.Error(),
+		err:     err,
+	}
+}
+
+// Localize translates the message and the field errors into lang.
+func (e *Error) Localize(lang errof.Lang) *Error {
+	if e.err != nil {
+		e.Message = errof.Localize(e.err, lang)
+	}
+	if fields, ok := e.Data.([]FieldError); ok {
+		for i := range fields {
+			fields[i].Message = errof.ValidationMessage(fields[i].Tag, fields[i].Param, lang)
+		}
 	}
+	return e
 }
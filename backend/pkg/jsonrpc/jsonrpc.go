@@ -7,12 +7,15 @@ import (
 	"net/http"
 
 	"github.com/friendsofgo/errors"
-	"github.com/omeroid/wdc/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/errof"
 	"github.com/volatiletech/null/v8"
 )
 
 const (
 	jsonrpc = "2.0"
+
+	// Version is the JSON-RPC protocol version.
+	Version = jsonrpc
 )
 
 var (
@@ -24,17 +27,29 @@ var (
 type Request struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
-	Headers http.Header     `json:"_"`
-	Params  json.RawMessage `json:"params"`
-	ID      interface{}     `json:"id"`
+	Headers http.Header     `json:"-"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      interface{}     `json:"id,omitempty"`
+	// Err : 不正なリクエストだった場合のエラー、実行せずにエラーを返す
+	Err error `json:"-"`
+
+	// nullID : "id": nullが指定された場合、通知ではなくidがnullのレスポンスを返す
+	nullID bool
+}
+
+// IsNotification reports whether the request is a notification.
+// idのメンバーを持たないリクエストは実行するが、レスポンスは返さない
+func (r *Request) IsNotification() bool {
+	return r.Err == nil && r.ID == nil && !r.nullID
 }
 
 // Return : JSONRPCReturn
 type Return struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id"`
-	Error   *Error      `json:"error,omitempty"`
-	Result  interface{} `json:"result,omitempty"`
+	JSONRPC      string      `json:"jsonrpc"`
+	ID           interface{} `json:"id"`
+	Error        *Error      `json:"error,omitempty"`
+	Result       interface{} `json:"result,omitempty"`
+	Notification bool        `json:"-"`
 }
 
 // Credential :
@@ -47,41 +62,66 @@ type Credential struct {
 }
 
 // Parse : ParseJSONRPC
-func Parse(r *http.Request) (requests []*Request, err error) {
-	buf := bytes.NewBuffer(make([]byte, 0, r.ContentLength))
-	if _, err := buf.ReadFrom(r.Body); err != nil {
-		return nil, errors.WithStack(errof.ErrInvalidRequest)
-	}
+// バッチ内の不正なリクエストはRequest.Errにエラーを持つ
+// batchはリクエストが配列だったか、WriteResponsesに渡す
+func Parse(r *http.Request, limits Limits) (requests []*Request, batch bool, err error) {
 	defer r.Body.Close()
+	if err := limits.checkBodyBytes(r.ContentLength); err != nil {
+		return nil, false, err
+	}
+
+	// 上限+1バイトまで読み、超えていれば上限超過とする
+	body := io.Reader(r.Body)
+	if 0 < limits.MaxBodyBytes {
+		body = io.LimitReader(r.Body, limits.MaxBodyBytes+1)
+	}
+	size := r.ContentLength
+	if size < 0 || (0 < limits.MaxBodyBytes && limits.MaxBodyBytes < size) {
+		size = 0
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	if _, err := buf.ReadFrom(body); err != nil {
+		return nil, false, errors.WithStack(errof.ErrInvalidRequest)
+	}
+	if err := limits.checkBodyBytes(int64(buf.Len())); err != nil {
+		return nil, false, err
+	}
 
 	if buf.Len() == 0 {
-		return nil, errors.WithStack(errof.ErrInvalidRequest)
+		return nil, false, errors.WithStack(errof.ErrInvalidRequest)
 	}
-	return parse(buf)
+	return parse(buf, limits)
 }
 
-func parse(buf *bytes.Buffer) (requests []*Request, err error) {
-	// read first rune
-	f, _, err := buf.ReadRune()
-	if err != nil {
-		return nil, errors.WithStack(errof.ErrInvalidRequest)
+// ParseBytes parses a JSON-RPC request or batch that is not read from an HTTP body,
+// e.g. a WebSocket frame.
+func ParseBytes(b []byte, limits Limits) (requests []*Request, batch bool, err error) {
+	if err := limits.checkBodyBytes(int64(len(b))); err != nil {
+		return nil, false, err
 	}
-	if err := buf.UnreadRune(); err != nil {
-		return nil, errors.WithStack(errof.ErrInvalidRequest)
+	if len(b) == 0 {
+		return nil, false, errors.WithStack(errof.ErrInvalidRequest)
+	}
+	return parse(bytes.NewBuffer(b), limits)
+}
+
+func parse(buf *bytes.Buffer, limits Limits) (requests []*Request, batch bool, err error) {
+	if err := limits.checkDepth(buf.Bytes()); err != nil {
+		return nil, false, err
+	}
+
+	// read first rune, JSONの前の空白は読み飛ばす
+	trimmed := bytes.TrimLeft(buf.Bytes(), " \t\r\n")
+	if len(trimmed) == 0 {
+		return nil, false, errors.WithStack(errof.ErrInvalidRequest)
 	}
 
 	// not batch
-	if f != batchRequestOpenToken {
-		r := &Request{}
-		if err := json.Unmarshal(buf.Bytes(), r); err != nil {
-			switch err.(type) {
-			case *json.SyntaxError:
-				return nil, errors.WithStack(errof.ErrParse)
-			case *json.UnmarshalTypeError:
-				return nil, errors.WithStack(errof.ErrInvalidRequest)
-			}
+	if rune(trimmed[0]) != batchRequestOpenToken {
+		if !json.Valid(buf.Bytes()) {
+			return nil, false, errors.WithStack(errof.ErrParse)
 		}
-		return []*Request{r}, nil
+		return []*Request{parseRequest(buf.Bytes())}, false, nil
 	}
 
 	// batch
@@ -90,50 +130,112 @@ func parse(buf *bytes.Buffer) (requests []*Request, err error) {
 	// read open bracket
 	t, err := d.Token()
 	if err != nil {
-		return nil, errors.Wrap(errof.ErrParse, "Failed to read open bracket")
+		return nil, false, errors.Wrap(errof.ErrParse, "Failed to read open bracket")
 	}
 
 	if t != json.Delim(batchRequestOpenToken) {
-		return nil, errors.Wrap(errof.ErrParse, "Invalid open token")
+		return nil, false, errors.Wrap(errof.ErrParse, "Invalid open token")
 	}
 
 	for d.More() {
-		r := &Request{}
-		if err = d.Decode(r); err != nil {
-			switch err.(type) {
-			case *json.SyntaxError:
-				return nil, errors.Wrap(errof.ErrParse, "Failed to decode batch request")
-			case *json.UnmarshalTypeError:
-				return nil, errors.Wrap(errof.ErrInvalidRequest, err.Error())
-			}
+		if err := limits.checkBatchSize(len(requests) + 1); err != nil {
+			return nil, false, err
 		}
-		if r.JSONRPC == "" || r.Method == "" || r.ID == nil {
-			return nil, errors.Wrap(errof.ErrInvalidRequest, "'jsonrpc', 'method' and 'id' are required")
+		var raw json.RawMessage
+		if err = d.Decode(&raw); err != nil {
+			return nil, false, errors.Wrap(errof.ErrParse, "Failed to decode batch request")
 		}
-		requests = append(requests, r)
+		requests = append(requests, parseRequest(raw))
 	}
 	// read closing bracket
 	t, err = d.Token()
 	if err != nil || t != json.Delim(batchRequestCloseToken) {
-		return nil, errors.Wrap(errof.ErrParse, "Invalid closing token")
+		return nil, false, errors.Wrap(errof.ErrParse, "Invalid closing token")
+	}
+	// 空の配列は正しいJSONだが、不正なリクエストとしてバッチではない1つのエラーを返す
+	if len(requests) == 0 {
+		return nil, false, errors.Wrap(errof.ErrInvalidRequest, "empty batch")
+	}
+	return requests, true, nil
+}
+
+// Responses drops the returns of notifications.
+func Responses(returns []*Return) []*Return {
+	responses := make([]*Return, 0, len(returns))
+	for _, r := range returns {
+		if r.Notification {
+			continue
+		}
+		responses = append(responses, r)
 	}
-	return requests, err
+	return responses
 }
 
-// WriteResponses writes responses
-func WriteResponses(w io.Writer, returns ...*Return) (err error) {
+// parseRequest : 不正なリクエストはバッチ全体を失敗させず、Errに持たせて返す
+func parseRequest(raw json.RawMessage) *Request {
+	r := &Request{}
+	if err := json.Unmarshal(raw, r); err != nil {
+		// idだけでも読み取れればエラーのレスポンスに含める
+		var v struct {
+			ID interface{} `json:"id"`
+		}
+		_ = json.Unmarshal(raw, &v)
+		return &Request{ID: validID(v.ID), Err: errors.Wrap(errof.ErrInvalidRequest, err.Error())}
+	}
+	if id := validID(r.ID); id == nil && r.ID != nil {
+		r.ID = nil
+		r.Err = errors.Wrap(errof.ErrInvalidRequest, "'id' must be a string, number or null")
+		return r
+	}
+	// 通知かはidの値ではなく、idのメンバーがあるかで判定する
+	if r.ID == nil {
+		var v struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.Unmarshal(raw, &v)
+		r.nullID = v.ID != nil
+	}
+	if r.JSONRPC == "" || r.Method == "" {
+		r.Err = errors.Wrap(errof.ErrInvalidRequest, "'jsonrpc' and 'method' are required")
+	}
+	return r
+}
+
+// validID : 仕様上idに使えるのは文字列、数値、nullのみ
+func validID(id interface{}) interface{} {
+	switch id.(type) {
+	case string, float64:
+		return id
+	}
+	return nil
+}
+
+// WriteResponses writes responses.
+// If batch is true the responses are written as an array, even when only one is left.
+// If every return belongs to a notification, nothing is written and
+// an http.ResponseWriter gets 204 No Content.
+func WriteResponses(w io.Writer, batch bool, returns ...*Return) (err error) {
+	notified := len(returns)
+	returns = Responses(returns)
+	if len(returns) == 0 && 0 < notified {
+		if rw, ok := w.(http.ResponseWriter); ok {
+			rw.WriteHeader(http.StatusNoContent)
+		}
+		return nil
+	}
+
 	for _, r := range returns {
 		r.JSONRPC = jsonrpc
 	}
 
-	if len(returns) == 1 {
+	if len(returns) == 1 && !batch {
 		if err = json.NewEncoder(w).Encode(returns[0]); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	if 1 < len(returns) {
+	if 0 < len(returns) {
 		if err = json.NewEncoder(w).Encode(returns); err != nil {
 			return err
 		}
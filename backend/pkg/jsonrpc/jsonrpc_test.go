@@ -0,0 +1,243 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+)
+
+func TestIsNotification(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"no id", `{"jsonrpc":"2.0","method":"a"}`, true},
+		{"null id", `{"jsonrpc":"2.0","method":"a","id":null}`, false},
+		{"number id", `{"jsonrpc":"2.0","method":"a","id":1}`, false},
+		{"string id", `{"jsonrpc":"2.0","method":"a","id":"1"}`, false},
+		{"invalid request without id", `{"jsonrpc":"2.0"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, _, err := ParseBytes([]byte(tt.body), Limits{})
+			if err != nil {
+				t.Fatalf("ParseBytes() error = %v", err)
+			}
+			if got := requests[0].IsNotification(); got != tt.want {
+				t.Errorf("IsNotification() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteResponses(t *testing.T) {
+	call := func(id interface{}) *Return { return &Return{ID: id, Result: "ok"} }
+	notification := func() *Return { return &Return{Notification: true} }
+	tests := []struct {
+		name       string
+		batch      bool
+		returns    []*Return
+		want       string
+		wantStatus int
+	}{
+		{
+			name:       "single",
+			returns:    []*Return{call(1)},
+			want:       `{"jsonrpc":"2.0","id":1,"result":"ok"}`,
+			wantStatus: 200,
+		},
+		{
+			name:       "single with null id",
+			returns:    []*Return{call(nil)},
+			want:       `{"jsonrpc":"2.0","id":null,"result":"ok"}`,
+			wantStatus: 200,
+		},
+		{
+			name:       "batch",
+			batch:      true,
+			returns:    []*Return{call(1), call(2)},
+			want:       `[{"jsonrpc":"2.0","id":1,"result":"ok"},{"jsonrpc":"2.0","id":2,"result":"ok"}]`,
+			wantStatus: 200,
+		},
+		{
+			name:       "batch with one response left",
+			batch:      true,
+			returns:    []*Return{notification(), call(1)},
+			want:       `[{"jsonrpc":"2.0","id":1,"result":"ok"}]`,
+			wantStatus: 200,
+		},
+		{
+			name:       "batch of one",
+			batch:      true,
+			returns:    []*Return{call(1)},
+			want:       `[{"jsonrpc":"2.0","id":1,"result":"ok"}]`,
+			wantStatus: 200,
+		},
+		{
+			name:       "only notifications",
+			batch:      true,
+			returns:    []*Return{notification(), notification()},
+			want:       ``,
+			wantStatus: 204,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := WriteResponses(w, tt.batch, tt.returns...); err != nil {
+				t.Fatalf("WriteResponses() error = %v", err)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBatchFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"single", `{"jsonrpc":"2.0","method":"a","id":1}`, false},
+		{"batch of one", `[{"jsonrpc":"2.0","method":"a","id":1}]`, true},
+		{"batch with leading space", ` [{"jsonrpc":"2.0","method":"a","id":1}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", bytes.NewBufferString(tt.body))
+			_, batch, err := Parse(r, Limits{})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if batch != tt.want {
+				t.Errorf("batch = %v, want %v", batch, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// wantErr : リクエスト全体のエラー
+		wantErr error
+		// wantIDs, wantErrs : リクエスト毎のidとエラー
+		wantIDs  []interface{}
+		wantErrs []error
+	}{
+		{
+			name:     "single",
+			body:     `{"jsonrpc":"2.0","method":"a","id":1}`,
+			wantIDs:  []interface{}{float64(1)},
+			wantErrs: []error{nil},
+		},
+		{
+			name:    "invalid json",
+			body:    `{"jsonrpc":"2.0","method":"a",`,
+			wantErr: errof.ErrParse,
+		},
+		{
+			name:    "invalid json in batch",
+			body:    `[{"jsonrpc":"2.0","method":"a","id":1},`,
+			wantErr: errof.ErrParse,
+		},
+		{
+			name:    "empty body",
+			body:    ``,
+			wantErr: errof.ErrInvalidRequest,
+		},
+		{
+			name:    "empty batch",
+			body:    `[]`,
+			wantErr: errof.ErrInvalidRequest,
+		},
+		{
+			name: "mixed batch",
+			body: `[
+				{"jsonrpc":"2.0","method":"a","id":1},
+				{"jsonrpc":"2.0","id":2},
+				1,
+				{"jsonrpc":"2.0","method":"b","id":"3"},
+				{"jsonrpc":"2.0","method":"c"}
+			]`,
+			wantIDs:  []interface{}{float64(1), float64(2), nil, "3", nil},
+			wantErrs: []error{nil, errof.ErrInvalidRequest, errof.ErrInvalidRequest, nil, nil},
+		},
+		{
+			name:     "missing method",
+			body:     `{"jsonrpc":"2.0","id":1}`,
+			wantIDs:  []interface{}{float64(1)},
+			wantErrs: []error{errof.ErrInvalidRequest},
+		},
+		{
+			name:     "missing jsonrpc",
+			body:     `{"method":"a","id":1}`,
+			wantIDs:  []interface{}{float64(1)},
+			wantErrs: []error{errof.ErrInvalidRequest},
+		},
+		{
+			name:     "method of wrong type",
+			body:     `{"jsonrpc":"2.0","method":1,"id":1}`,
+			wantIDs:  []interface{}{float64(1)},
+			wantErrs: []error{errof.ErrInvalidRequest},
+		},
+		{
+			name: "invalid id types",
+			body: `[
+				{"jsonrpc":"2.0","method":"a","id":true},
+				{"jsonrpc":"2.0","method":"a","id":{}},
+				{"jsonrpc":"2.0","method":"a","id":[1]}
+			]`,
+			wantIDs:  []interface{}{nil, nil, nil},
+			wantErrs: []error{errof.ErrInvalidRequest, errof.ErrInvalidRequest, errof.ErrInvalidRequest},
+		},
+		{
+			name:     "non object items",
+			body:     `[1, "a", null, []]`,
+			wantIDs:  []interface{}{nil, nil, nil, nil},
+			wantErrs: []error{errof.ErrInvalidRequest, errof.ErrInvalidRequest, errof.ErrInvalidRequest, errof.ErrInvalidRequest},
+		},
+		{
+			name:     "non object single",
+			body:     `"a"`,
+			wantIDs:  []interface{}{nil},
+			wantErrs: []error{errof.ErrInvalidRequest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, _, err := ParseBytes([]byte(tt.body), Limits{})
+			if tt.wantErr != nil {
+				if errors.Cause(err) != tt.wantErr {
+					t.Fatalf("ParseBytes() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBytes() error = %v", err)
+			}
+			if len(requests) != len(tt.wantIDs) {
+				t.Fatalf("len(requests) = %d, want %d", len(requests), len(tt.wantIDs))
+			}
+			for i, r := range requests {
+				if r.ID != tt.wantIDs[i] {
+					t.Errorf("requests[%d].ID = %v, want %v", i, r.ID, tt.wantIDs[i])
+				}
+				if errors.Cause(r.Err) != tt.wantErrs[i] {
+					t.Errorf("requests[%d].Err = %v, want %v", i, r.Err, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
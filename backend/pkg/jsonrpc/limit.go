@@ -0,0 +1,63 @@
+package jsonrpc
+
+import (
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+)
+
+// Limits : Parseでのリクエストの上限、0以下の値は無制限
+type Limits struct {
+	MaxBodyBytes int64
+	MaxBatchSize int
+	// MaxDepth : 配列とオブジェクトの入れ子の深さ、バッチの配列も含む
+	MaxDepth int
+}
+
+func (l Limits) checkBodyBytes(n int64) error {
+	if 0 < l.MaxBodyBytes && l.MaxBodyBytes < n {
+		return errors.WithStack(&LimitError{Err: errof.ErrRequestTooLarge, Name: "max_body_bytes", Limit: l.MaxBodyBytes})
+	}
+	return nil
+}
+
+func (l Limits) checkBatchSize(n int) error {
+	if 0 < l.MaxBatchSize && l.MaxBatchSize < n {
+		return errors.WithStack(&LimitError{Err: errof.ErrBatchTooLarge, Name: "max_batch_size", Limit: int64(l.MaxBatchSize)})
+	}
+	return nil
+}
+
+// checkDepth : 文字列の外にある括弧の深さを数える
+// 不正なJSONはここではエラーにせず、後のパースに任せる
+func (l Limits) checkDepth(b []byte) error {
+	if l.MaxDepth <= 0 {
+		return nil
+	}
+	var depth int
+	var inString, escaped bool
+	for _, c := range b {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+			if l.MaxDepth < depth {
+				return errors.WithStack(&LimitError{Err: errof.ErrNestingTooDeep, Name: "max_depth", Limit: int64(l.MaxDepth)})
+			}
+		case ']', '}':
+			depth--
+		}
+	}
+	return nil
+}
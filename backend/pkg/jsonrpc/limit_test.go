@@ -0,0 +1,99 @@
+package jsonrpc
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+)
+
+func TestParseLimits(t *testing.T) {
+	call := `{"jsonrpc":"2.0","method":"a","id":1}`
+	tests := []struct {
+		name   string
+		body   string
+		limits Limits
+		// chunked : Content-Lengthがない場合も読んだバイト数で判定する
+		chunked  bool
+		wantErr  error
+		wantName string
+	}{
+		{
+			name:   "no limits",
+			body:   "[" + strings.Repeat(call+",", 99) + call + "]",
+			limits: Limits{},
+		},
+		{
+			name:   "body at the limit",
+			body:   call,
+			limits: Limits{MaxBodyBytes: int64(len(call))},
+		},
+		{
+			name:     "body over the limit",
+			body:     call,
+			limits:   Limits{MaxBodyBytes: int64(len(call)) - 1},
+			wantErr:  errof.ErrRequestTooLarge,
+			wantName: "max_body_bytes",
+		},
+		{
+			name:     "chunked body over the limit",
+			body:     call,
+			limits:   Limits{MaxBodyBytes: int64(len(call)) - 1},
+			chunked:  true,
+			wantErr:  errof.ErrRequestTooLarge,
+			wantName: "max_body_bytes",
+		},
+		{
+			name:   "batch at the limit",
+			body:   "[" + call + "," + call + "]",
+			limits: Limits{MaxBatchSize: 2},
+		},
+		{
+			name:     "batch over the limit",
+			body:     "[" + call + "," + call + "," + call + "]",
+			limits:   Limits{MaxBatchSize: 2},
+			wantErr:  errof.ErrBatchTooLarge,
+			wantName: "max_batch_size",
+		},
+		{
+			name:   "depth at the limit",
+			body:   `[{"jsonrpc":"2.0","method":"a","params":[1],"id":1}]`,
+			limits: Limits{MaxDepth: 3},
+		},
+		{
+			name:     "depth over the limit",
+			body:     `[{"jsonrpc":"2.0","method":"a","params":[[1]],"id":1}]`,
+			limits:   Limits{MaxDepth: 3},
+			wantErr:  errof.ErrNestingTooDeep,
+			wantName: "max_depth",
+		},
+		{
+			name:   "brackets in strings",
+			body:   `{"jsonrpc":"2.0","method":"a","params":["[[{{\"[["],"id":1}`,
+			limits: Limits{MaxDepth: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+				r.Body = io.NopCloser(strings.NewReader(tt.body))
+			}
+			_, _, err := Parse(r, tt.limits)
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Name != tt.wantName {
+				t.Errorf("Parse() error = %#v, want LimitError %s", err, tt.wantName)
+			}
+		})
+	}
+}
@@ -1,18 +1,27 @@
 package logger
 
 import (
+	"math/rand"
 	"os"
 
+	"github.com/httptest/backend/pkg/config"
 	"github.com/inconshreveable/log15"
-	"github.com/omeroid/wdc/backend/pkg/config"
 )
 
+// Access : アクセスログ、呼び出し元のスタックは不要なので別に出力する
+var Access = log15.New("log", "access")
+
+// successSampleRate : 未設定の場合は全て出力する
+var successSampleRate = 1.0
+
 // InitLogger :
 func InitLogger(c config.Logger) {
 	stackHandler := log15.CallerStackHandler("%+v", log15.StderrHandler)
+	accessHandler := log15.StreamHandler(os.Stdout, log15.LogfmtFormat())
 	if c.LogJSON {
 		s := log15.StreamHandler(os.Stderr, log15.JsonFormatEx(false, true))
 		stackHandler = log15.CallerStackHandler("%+v", s)
+		accessHandler = log15.StreamHandler(os.Stdout, log15.JsonFormatEx(false, true))
 	}
 
 	lvlFilterHandler := log15.LvlFilterHandler(log15.LvlInfo, stackHandler)
@@ -20,4 +29,20 @@ func InitLogger(c config.Logger) {
 		lvlFilterHandler = log15.LvlFilterHandler(log15.LvlDebug, stackHandler)
 	}
 	log15.Root().SetHandler(lvlFilterHandler)
+	Access.SetHandler(accessHandler)
+	successSampleRate = 1
+	if c.SuccessSampleRate != nil {
+		successSampleRate = *c.SuccessSampleRate
+	}
+}
+
+// SampleSuccess : 成功した呼び出しのアクセスログを出力するか
+func SampleSuccess() bool {
+	if successSampleRate <= 0 {
+		return false
+	}
+	if 1 <= successSampleRate {
+		return true
+	}
+	return rand.Float64() < successSampleRate
 }
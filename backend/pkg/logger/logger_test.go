@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/httptest/backend/pkg/config"
+)
+
+func TestSampleSuccess(t *testing.T) {
+	rate := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name string
+		rate *float64
+		want bool
+	}{
+		{name: "unset", want: true},
+		{name: "one", rate: rate(1), want: true},
+		{name: "over one", rate: rate(2), want: true},
+		{name: "zero", rate: rate(0), want: false},
+		{name: "negative", rate: rate(-1), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InitLogger(config.Logger{SuccessSampleRate: tt.rate})
+			t.Cleanup(func() { InitLogger(config.Logger{}) })
+			for i := 0; i < 10; i++ {
+				if got := SampleSuccess(); got != tt.want {
+					t.Fatalf("SampleSuccess() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/inconshreveable/log15"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace   = "rpc"
+	defaultPath = "/metrics"
+	// UnknownMethod : funcMapにないメソッド名は、ラベルの種類が増えないようにまとめる
+	UnknownMethod = "unknown"
+)
+
+var (
+	// Calls : メソッド、エラーコード毎の呼び出し数 (成功は0)
+	Calls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "calls_total",
+		Help:      "Number of JSON-RPC calls by method and error code (0 on success).",
+	}, []string{"method", "code"})
+
+	// CallDuration : メソッド、エラーコード毎の実行時間
+	CallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "call_duration_seconds",
+		Help:      "Latency of JSON-RPC calls by method and error code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// BatchSize : 1リクエストあたりの呼び出し数
+	BatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "Number of calls in a JSON-RPC request.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100},
+	})
+
+	// InFlightRequests : 処理中のHTTPリクエスト、WebSocketのフレーム数
+	InFlightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "in_flight_requests",
+		Help:      "Number of JSON-RPC requests being processed.",
+	})
+
+	// InFlightCalls : 実行中のメソッド数
+	InFlightCalls = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "in_flight_calls",
+		Help:      "Number of JSON-RPC calls being executed.",
+	})
+
+	// Panics : メソッド毎のrecoverしたpanicの数
+	Panics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "panics_total",
+		Help:      "Number of panics recovered in JSON-RPC methods.",
+	}, []string{"method"})
+)
+
+// ObserveCall :
+func ObserveCall(method string, code int, duration time.Duration) {
+	c := strconv.Itoa(code)
+	Calls.WithLabelValues(method, c).Inc()
+	CallDuration.WithLabelValues(method, c).Observe(duration.Seconds())
+}
+
+// NewServer : RPCとは別のポートで/metricsを提供する、c.Portが0以下の場合はnil
+func NewServer(c config.Metrics) *http.Server {
+	if c.Port <= 0 {
+		return nil
+	}
+	path := c.Path
+	if path == "" {
+		path = defaultPath
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.Handler())
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", c.Port),
+		Handler: mux,
+	}
+}
+
+// Serve : srvがnilの場合は何もしない
+func Serve(srv *http.Server) {
+	if srv == nil {
+		return
+	}
+	log15.Info("metrics listening....", "method", "metrics.Serve", "addr", srv.Addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log15.Error("Metrics server closed with error", "err", err)
+	}
+}
+
+// Shutdown : srvがnilの場合は何もしない
+func Shutdown(ctx context.Context, srv *http.Server) {
+	if srv == nil {
+		return
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log15.Error("Failed to shutdown metrics server", "err", err)
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/util"
+)
+
+// sweepInterval : 満タンになったバケットを削除する間隔
+const sweepInterval = time.Minute
+
+// Limit : 1秒あたりRate個のトークンを補充し、Burst個まで貯めるトークンバケット
+// Rateが0以下の場合は制限しない
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// FromConfig :
+func FromConfig(c config.RateLimit) Limit {
+	return Limit{Rate: c.Rate, Burst: c.Burst}
+}
+
+// Enabled :
+func (l Limit) Enabled() bool {
+	return 0 < l.Rate
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// Store : キー毎のトークンバケット
+// 複数台で共有する場合はRedisなどで実装したものに差し替える
+type Store interface {
+	// Allow : n個のトークンを取る、取れない場合はn個貯まるまでの時間を返す
+	Allow(ctx context.Context, key string, limit Limit, n int) (ok bool, retryAfter time.Duration, err error)
+	// Check : Allowと同じ判定をトークンを取らずに行う
+	Check(ctx context.Context, key string, limit Limit, n int) (ok bool, retryAfter time.Duration, err error)
+}
+
+// NewStore :
+func NewStore() Store {
+	return NewMemoryStore()
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// fill : lastからの経過時間分のトークンを補充する
+func (b *bucket) fill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if 0 < elapsed {
+		b.tokens = math.Min(b.limit.burst(), b.tokens+elapsed*b.limit.Rate)
+	}
+	b.last = now
+}
+
+// MemoryStore : プロセス内のメモリに持つ
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore :
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]*bucket{},
+		lastSweep: util.TimeNowFunc(),
+	}
+}
+
+// Allow : nがBurstより多い場合は、バケットが満タンの時のみ許可する
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit, n int) (bool, time.Duration, error) {
+	ok, retryAfter := s.take(key, limit, n, true)
+	return ok, retryAfter, nil
+}
+
+// Check :
+func (s *MemoryStore) Check(_ context.Context, key string, limit Limit, n int) (bool, time.Duration, error) {
+	ok, retryAfter := s.take(key, limit, n, false)
+	return ok, retryAfter, nil
+}
+
+// take : consumeがfalseの場合は判定のみ行う
+func (s *MemoryStore) take(key string, limit Limit, n int, consume bool) (bool, time.Duration) {
+	if !limit.Enabled() {
+		return true, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := util.TimeNowFunc()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.burst(), last: now, limit: limit}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.fill(now)
+
+	need := math.Min(float64(n), limit.burst())
+	if need <= b.tokens {
+		if consume {
+			b.tokens -= need
+		}
+		return true, 0
+	}
+	retryAfter := time.Duration((need - b.tokens) / limit.Rate * float64(time.Second))
+	return false, retryAfter
+}
+
+// sweep : 満タンのバケットは新しく作るのと同じなので削除する
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		b.fill(now)
+		if b.limit.burst() <= b.tokens {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/httptest/backend"
+
+	// ExporterNone : spanを送信しない (traceparentの伝播は行う)
+	ExporterNone = "none"
+	// ExporterOTLP : OTLP/HTTPでc.Endpointに送信する
+	ExporterOTLP = "otlp"
+	// ExporterStdout : 標準出力にJSONで出力する
+	ExporterStdout = "stdout"
+	// ExporterFile : c.FilePathにJSONで追記する (オフラインのテスト用)
+	ExporterFile = "file"
+)
+
+// Init : c.ExporterのTracerProviderとW3C Trace Contextのpropagatorを設定する
+// 終了時にshutdownを呼び、残りのspanを送信する
+func Init(ctx context.Context, c config.Tracing, serviceName string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch c.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if exporter, err = otlptracehttp.New(ctx, opts...); err != nil {
+			return nil, errors.Wrap(err, "failed to create otlp exporter")
+		}
+	case ExporterStdout:
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout)); err != nil {
+			return nil, errors.Wrap(err, "failed to create stdout exporter")
+		}
+	case ExporterFile:
+		f, err := os.OpenFile(c.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open trace file: %s", c.FilePath)
+		}
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(f)); err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "failed to create file exporter")
+		}
+		closer = f
+	default:
+		return nil, errors.Errorf("unknown tracing exporter: %s", c.Exporter)
+	}
+
+	// SampleRatioが0以下、1以上の場合は全て記録する
+	sampler := sdktrace.AlwaysSample()
+	if 0 < c.SampleRatio && c.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(c.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Tracer :
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Extract : traceparentヘッダのtrace contextをctxに入れる
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceID : ctxにspanがない場合は空文字
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
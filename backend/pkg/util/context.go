@@ -3,17 +3,26 @@ package util
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
 )
 
 // https://deeeet.com/writing/2017/02/23/go-context-value/
 type contextKey string
 
 var (
-	orgIDContextKey     contextKey = "orgID"
-	userIDContextKey    contextKey = "userID"
-	deviceIDContextKey  contextKey = "deviceID"
-	ipAddressContextKey contextKey = "ipAddress"
-	dbTxContextKey      contextKey = "dbTx"
+	orgIDContextKey       contextKey = "orgID"
+	userIDContextKey      contextKey = "userID"
+	deviceIDContextKey    contextKey = "deviceID"
+	ipAddressContextKey   contextKey = "ipAddress"
+	dbTxContextKey        contextKey = "dbTx"
+	langContextKey        contextKey = "lang"
+	credentialContextKey  contextKey = "credential"
+	rolesContextKey       contextKey = "roles"
+	requestIDContextKey   contextKey = "requestID"
+	tokenExpiryContextKey contextKey = "tokenExpiry"
 )
 
 type withoutCancel struct {
@@ -42,6 +51,108 @@ func GetIPAddress(ctx context.Context) string {
 	return ipAddress
 }
 
+// SetOrgID :
+func SetOrgID(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgIDContextKey, orgID)
+}
+
+// GetOrgID : OrgCodeが指定されていない場合は空文字
+func GetOrgID(ctx context.Context) string {
+	if orgID, ok := ctx.Value(orgIDContextKey).(string); ok {
+		return orgID
+	}
+	return ""
+}
+
+// SetDeviceID :
+func SetDeviceID(ctx context.Context, deviceID string) context.Context {
+	return context.WithValue(ctx, deviceIDContextKey, deviceID)
+}
+
+// GetDeviceID : 端末として認証されていない場合は空文字
+func GetDeviceID(ctx context.Context) string {
+	if deviceID, ok := ctx.Value(deviceIDContextKey).(string); ok {
+		return deviceID
+	}
+	return ""
+}
+
+// SetUserID :
+func SetUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey, userID)
+}
+
+// GetUserID : 認証されていない場合は空文字
+func GetUserID(ctx context.Context) string {
+	if userID, ok := ctx.Value(userIDContextKey).(string); ok {
+		return userID
+	}
+	return ""
+}
+
+// SetCredential :
+func SetCredential(ctx context.Context, credential jsonrpc.Credential) context.Context {
+	return context.WithValue(ctx, credentialContextKey, credential)
+}
+
+// GetCredential : 認証されていない場合はfalse
+func GetCredential(ctx context.Context) (jsonrpc.Credential, bool) {
+	credential, ok := ctx.Value(credentialContextKey).(jsonrpc.Credential)
+	return credential, ok
+}
+
+// SetRoles :
+func SetRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesContextKey, roles)
+}
+
+// GetRoles : 設定されていない場合はnil
+func GetRoles(ctx context.Context) []string {
+	if roles, ok := ctx.Value(rolesContextKey).([]string); ok {
+		return roles
+	}
+	return nil
+}
+
+// SetRequestID :
+func SetRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// GetRequestID :
+func GetRequestID(ctx context.Context) string {
+	if requestID, ok := ctx.Value(requestIDContextKey).(string); ok {
+		return requestID
+	}
+	return ""
+}
+
+// SetTokenExpiry : 認証に使ったIDトークンの有効期限
+func SetTokenExpiry(ctx context.Context, expiry time.Time) context.Context {
+	return context.WithValue(ctx, tokenExpiryContextKey, expiry)
+}
+
+// GetTokenExpiry : 有効期限のない認証 (端末など) の場合はゼロ値
+func GetTokenExpiry(ctx context.Context) time.Time {
+	if expiry, ok := ctx.Value(tokenExpiryContextKey).(time.Time); ok {
+		return expiry
+	}
+	return time.Time{}
+}
+
+// SetLang :
+func SetLang(ctx context.Context, lang errof.Lang) context.Context {
+	return context.WithValue(ctx, langContextKey, lang)
+}
+
+// GetLang : 設定されていない場合はerrof.DefaultLang
+func GetLang(ctx context.Context) errof.Lang {
+	if lang, ok := ctx.Value(langContextKey).(errof.Lang); ok {
+		return lang
+	}
+	return errof.DefaultLang
+}
+
 // SetDBTx :
 func SetDBTx(ctx context.Context, dbTx *sql.Tx) context.Context {
 	return context.WithValue(ctx, dbTxContextKey, dbTx)
@@ -0,0 +1,160 @@
+package util
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// クライアントのIPアドレスを読むヘッダ
+// プロキシが追記しないヘッダはクライアントが自由に書けるため、どちらか1つだけを読む
+const (
+	// ForwardedHeaderXForwardedFor : X-Forwarded-For (nginx, ALBなど)
+	ForwardedHeaderXForwardedFor = "x-forwarded-for"
+	// ForwardedHeaderForwarded : RFC 7239 のForwarded
+	ForwardedHeaderForwarded = "forwarded"
+)
+
+// IPResolver : 信頼するプロキシを経由したリクエストから、クライアントのIPアドレスを求める
+type IPResolver struct {
+	trusted []*net.IPNet
+	header  string
+}
+
+// NewIPResolver : trustedProxiesはCIDRまたはIPアドレス、headerはForwardedHeader* (空の場合はX-Forwarded-For)
+func NewIPResolver(trustedProxies []string, header string) (*IPResolver, error) {
+	r := &IPResolver{header: strings.ToLower(strings.TrimSpace(header))}
+	switch r.header {
+	case "":
+		r.header = ForwardedHeaderXForwardedFor
+	case ForwardedHeaderXForwardedFor, ForwardedHeaderForwarded:
+	default:
+		return nil, errors.Errorf("invalid forwarded header: %s", header)
+	}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, errors.Errorf("invalid trusted proxy: %s", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted proxy: %s", proxy)
+		}
+		r.trusted = append(r.trusted, ipNet)
+	}
+	return r, nil
+}
+
+// ClientIP : RemoteAddrが信頼するプロキシの場合のみ、設定したヘッダを右から辿り
+// 最初の信頼しないアドレスをクライアントとする
+func (r *IPResolver) ClientIP(req *http.Request) string {
+	remote := parseIP(req.RemoteAddr)
+	if remote == nil {
+		return ""
+	}
+	if !r.isTrusted(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	switch r.header {
+	case ForwardedHeaderForwarded:
+		hops = forwardedFor(req.Header.Values("Forwarded"))
+	default:
+		hops = xForwardedFor(req.Header.Values("X-Forwarded-For"))
+	}
+
+	client := remote
+	for i := len(hops) - 1; 0 <= i; i-- {
+		ip := parseIP(hops[i])
+		// 不正な値 (unknownや難読化された識別子) より先は信頼できない
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !r.isTrusted(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (r *IPResolver) isTrusted(ip net.IP) bool {
+	for _, ipNet := range r.trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// xForwardedFor : 複数のヘッダ、カンマ区切りの値を順に並べる
+func xForwardedFor(values []string) (hops []string) {
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwardedFor : RFC 7239 のfor=の値を順に並べる
+// Forwarded: for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"
+func forwardedFor(values []string) (hops []string) {
+	for _, value := range values {
+		for _, element := range splitOutsideQuotes(value, ',') {
+			hop := ""
+			for _, pair := range splitOutsideQuotes(element, ';') {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hop = strings.Trim(val, `"`)
+				}
+			}
+			// for=がない要素も経路の1つとして数える
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+func splitOutsideQuotes(s string, sep byte) (parts []string) {
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseIP : ポート番号、IPv6の[]を除いてパースする
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	// IPv6のゾーン (fe80::1%eth0) は除く
+	if i := strings.IndexByte(addr, '%'); 0 <= i {
+		addr = addr[:i]
+	}
+	return net.ParseIP(addr)
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8::1"}
+	tests := []struct {
+		name    string
+		header  string
+		remote  string
+		forward []string
+		xff     []string
+		want    string
+	}{
+		{
+			name:   "untrusted remote",
+			remote: "192.0.2.1:1234",
+			xff:    []string{"203.0.113.9"},
+			want:   "192.0.2.1",
+		},
+		{
+			name:   "no header",
+			remote: "10.0.0.5:1234",
+			want:   "10.0.0.5",
+		},
+		{
+			name:   "trusted hops",
+			remote: "10.0.0.5:1234",
+			xff:    []string{"198.51.100.7, 203.0.113.9, 10.0.0.9, 10.0.0.8"},
+			want:   "203.0.113.9",
+		},
+		{
+			name:   "multiple headers",
+			remote: "10.0.0.5:1234",
+			xff:    []string{"203.0.113.9", "10.0.0.8"},
+			want:   "203.0.113.9",
+		},
+		{
+			// クライアントが書いた値は信頼しないアドレスより左にあるため読まない
+			name:   "spoofed x-forwarded-for",
+			remote: "10.0.0.5:1234",
+			xff:    []string{"1.2.3.4, 203.0.113.9"},
+			want:   "203.0.113.9",
+		},
+		{
+			// x-forwarded-forを読む場合、クライアントが書いたForwardedは無視する
+			name:    "spoofed forwarded",
+			remote:  "10.0.0.5:1234",
+			forward: []string{"for=1.2.3.4"},
+			xff:     []string{"203.0.113.9"},
+			want:    "203.0.113.9",
+		},
+		{
+			name:   "unknown",
+			remote: "10.0.0.5:1234",
+			xff:    []string{"unknown, 10.0.0.8"},
+			want:   "10.0.0.8",
+		},
+		{
+			name:    "forwarded",
+			header:  ForwardedHeaderForwarded,
+			remote:  "10.0.0.5:1234",
+			forward: []string{`for=198.51.100.7;proto=https, for=203.0.113.9;by=10.0.0.5`},
+			xff:     []string{"1.2.3.4"},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "forwarded quoted ipv6 with port",
+			header:  ForwardedHeaderForwarded,
+			remote:  "[2001:db8::1]:443",
+			forward: []string{`for="[2001:db8:cafe::17]:4711"`},
+			want:    "2001:db8:cafe::17",
+		},
+		{
+			name:    "forwarded obfuscated",
+			header:  ForwardedHeaderForwarded,
+			remote:  "10.0.0.5:1234",
+			forward: []string{`for=203.0.113.9, for="_hidden", for=10.0.0.8`},
+			want:    "10.0.0.8",
+		},
+		{
+			name:    "forwarded without for",
+			header:  ForwardedHeaderForwarded,
+			remote:  "10.0.0.5:1234",
+			forward: []string{`for=203.0.113.9, proto=https`},
+			want:    "10.0.0.5",
+		},
+		{
+			// forwardedを読む場合、クライアントが書いたX-Forwarded-Forは無視する
+			name:   "forwarded ignores x-forwarded-for",
+			header: ForwardedHeaderForwarded,
+			remote: "10.0.0.5:1234",
+			xff:    []string{"1.2.3.4"},
+			want:   "10.0.0.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewIPResolver(trusted, tt.header)
+			if err != nil {
+				t.Fatalf("NewIPResolver() error = %v", err)
+			}
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.forward {
+				req.Header.Add("Forwarded", v)
+			}
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := r.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewIPResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		header  string
+		wantErr bool
+	}{
+		{name: "cidr and ip", proxies: []string{"10.0.0.0/8", "127.0.0.1", "::1"}},
+		{name: "forwarded", header: "Forwarded"},
+		{name: "invalid proxy", proxies: []string{"10.0.0"}, wantErr: true},
+		{name: "invalid cidr", proxies: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "invalid header", header: "x-real-ip", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewIPResolver(tt.proxies, tt.header); (err != nil) != tt.wantErr {
+				t.Errorf("NewIPResolver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
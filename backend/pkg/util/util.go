@@ -1,6 +1,8 @@
 package util
 
 import (
+	"crypto/rand"
+	"math/big"
 	"time"
 )
 
@@ -20,3 +22,17 @@ func InitLocale() {
 	}
 	time.Local = loc
 }
+
+// RandString : letterRunesからn文字のランダムな文字列を生成する
+func RandString(n int) string {
+	b := make([]rune, n)
+	max := big.NewInt(int64(len(letterRunes)))
+	for i := range b {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = letterRunes[v.Int64()]
+	}
+	return string(b)
+}
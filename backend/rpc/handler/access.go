@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/logger"
+	"github.com/httptest/backend/pkg/tracing"
+	"github.com/httptest/backend/pkg/util"
+)
+
+const maxRequestIDLength = 64
+
+// requestID : 形式が正しい場合はX-Request-IDを引き継ぎ、ない場合は生成する
+func requestID(r *http.Request) string {
+	id := r.Header.Get(XRequestID.String())
+	if validRequestID(id) {
+		return id
+	}
+	return util.RandString(20)
+}
+
+// validRequestID : ログを汚さないよう英数字と-_.のみ受け付ける
+func validRequestID(id string) bool {
+	if id == "" || maxRequestIDLength < len(id) {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// accessFields : contextの呼び出し元の情報
+func accessFields(ctx context.Context) []interface{} {
+	return []interface{}{
+		"request_id", util.GetRequestID(ctx),
+		"trace_id", tracing.TraceID(ctx),
+		"user_id", util.GetUserID(ctx),
+		"org_id", util.GetOrgID(ctx),
+		"device_id", util.GetDeviceID(ctx),
+		"ip", util.GetIPAddress(ctx),
+	}
+}
+
+// logCall : 1回の呼び出しのアクセスログ、成功した場合はサンプリングする
+func logCall(ctx context.Context, index int, method string, start time.Time, returns []*jsonrpc.Return) {
+	code := callCode(returns)
+	// クライアントが切断した場合はreturnsがない
+	if 0 < len(returns) && code == 0 && !logger.SampleSuccess() {
+		return
+	}
+	fields := append(accessFields(ctx),
+		"method", method,
+		"index", index,
+		"duration_ms", durationMs(start),
+		"code", code,
+	)
+	if len(returns) == 0 && ctx.Err() != nil {
+		fields = append(fields, "err", ctx.Err())
+	}
+	logger.Access.Info("rpc call", fields...)
+}
+
+// callCode : 呼び出しのエラーコード、成功した場合は0
+func callCode(returns []*jsonrpc.Return) int {
+	for _, r := range returns {
+		if r.Error != nil {
+			return int(r.Error.Code)
+		}
+	}
+	return 0
+}
+
+// logRequest : 1回のHTTPリクエストのアクセスログ、成功した場合はサンプリングする
+// リクエスト全体のエラーも200で返すため、成功したかはステータスコードではなく呼び出し側が決める
+func logRequest(ctx context.Context, r *http.Request, status int, success bool, start time.Time, extra ...interface{}) {
+	if success && !logger.SampleSuccess() {
+		return
+	}
+	fields := append(accessFields(ctx),
+		"http_method", r.Method,
+		"path", r.URL.Path,
+		"status", status,
+		"duration_ms", durationMs(start),
+	)
+	logger.Access.Info("http request", append(fields, extra...)...)
+}
+
+func durationMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// statusWriter : アクセスログのためにステータスコードを記録する
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
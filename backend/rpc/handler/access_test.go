@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/logger"
+	"github.com/inconshreveable/log15"
+)
+
+// recordAccessLog : テストの間、アクセスログのメッセージを記録する
+func recordAccessLog(t *testing.T, c config.Logger) *[]string {
+	t.Helper()
+	var messages []string
+	logger.InitLogger(c)
+	logger.Access.SetHandler(log15.FuncHandler(func(r *log15.Record) error {
+		messages = append(messages, r.Msg)
+		return nil
+	}))
+	t.Cleanup(func() { logger.InitLogger(config.Logger{}) })
+	return &messages
+}
+
+func TestLogRequestSampling(t *testing.T) {
+	none := 0.0
+	h := newTestHandler(t, config.HTTP{Concurrency: 1}, map[string]Func{
+		"sleep": Register("待つ", sleepFunc(&concurrencyCounter{}, false)),
+	})
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "success", body: `{"jsonrpc":"2.0","method":"sleep","id":1}`},
+		// リクエスト全体のエラーは200で返るが、成功として間引かない
+		{name: "parse error", body: `{"jsonrpc":"2.0"`, want: []string{"http request"}},
+		{name: "method error", body: `{"jsonrpc":"2.0","method":"nope","id":1}`, want: []string{"rpc call", "http request"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := recordAccessLog(t, config.Logger{SuccessSampleRate: &none})
+			serve(h, tt.body)
+			if len(*messages) != len(tt.want) {
+				t.Fatalf("messages = %v, want %v", *messages, tt.want)
+			}
+			for i := range tt.want {
+				if (*messages)[i] != tt.want[i] {
+					t.Errorf("messages = %v, want %v", *messages, tt.want)
+				}
+			}
+		})
+	}
+}
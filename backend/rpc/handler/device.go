@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/util"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Device : Firebaseのアカウントを持たない端末 (キオスクなど)
+type Device struct {
+	ID   string
+	Code string
+	// SecretHash : CardSecretのbcryptハッシュ、HashCardSecretで生成する
+	SecretHash []byte
+}
+
+// DeviceStore : DeviceCodeから端末を引く
+type DeviceStore interface {
+	// Device : 存在しない場合はerrof.ErrAuthentication
+	Device(ctx context.Context, deviceCode string) (Device, error)
+}
+
+// HashCardSecret : DeviceStoreに保存するCardSecretのハッシュを生成する
+func HashCardSecret(cardSecret string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(cardSecret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.Wrap(errof.ErrInternal, err.Error())
+	}
+	return hash, nil
+}
+
+// MemoryDeviceStore : メモリ上のDeviceから引く
+type MemoryDeviceStore struct {
+	mu      sync.RWMutex
+	devices map[string]Device
+}
+
+// NewDeviceStore :
+// DBなどから引く場合は、DeviceStoreを実装したものに差し替える
+func NewDeviceStore() DeviceStore {
+	return NewMemoryDeviceStore()
+}
+
+// NewMemoryDeviceStore :
+func NewMemoryDeviceStore(devices ...Device) *MemoryDeviceStore {
+	s := &MemoryDeviceStore{devices: map[string]Device{}}
+	for _, device := range devices {
+		s.Add(device)
+	}
+	return s
+}
+
+// Add : 同じCodeのDeviceは上書きする
+func (s *MemoryDeviceStore) Add(device Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices[device.Code] = device
+}
+
+func (s *MemoryDeviceStore) Device(_ context.Context, deviceCode string) (Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	device, ok := s.devices[deviceCode]
+	if !ok {
+		return Device{}, errors.Wrapf(errof.ErrAuthentication, "unknown device: %s", deviceCode)
+	}
+	return device, nil
+}
+
+const (
+	// deviceAuthTTL : 認証に成功したCardSecretをbcryptで比較し直さずに受け付ける期間
+	deviceAuthTTL = 5 * time.Minute
+	// deviceAuthSweepSize : キャッシュがこの数を超えたら期限切れのものを削除する
+	deviceAuthSweepSize = 1024
+)
+
+var (
+	dummySecretHashOnce  sync.Once
+	dummySecretHashBytes []byte
+)
+
+// dummySecretHash : 存在しない端末でも比較を行い、応答時間で端末の有無がわからないようにする
+// 起動を遅くしないよう、初めて使う時に生成する
+func dummySecretHash() []byte {
+	dummySecretHashOnce.Do(func() {
+		dummySecretHashBytes, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	})
+	return dummySecretHashBytes
+}
+
+// deviceAuthenticator : 端末は毎リクエストCardSecretを送るため、成功した組み合わせをdeviceAuthTTLの間キャッシュする
+// キャッシュはCardSecretのハッシュでのみ引き、SecretHashが変わった場合は比較し直す
+type deviceAuthenticator struct {
+	devices DeviceStore
+
+	mu      sync.Mutex
+	entries map[deviceAuthKey]deviceAuthEntry
+}
+
+type deviceAuthKey struct {
+	deviceCode string
+	secret     [sha256.Size]byte
+}
+
+type deviceAuthEntry struct {
+	secretHash []byte
+	expiresAt  time.Time
+}
+
+func newDeviceAuthenticator(devices DeviceStore) *deviceAuthenticator {
+	return &deviceAuthenticator{
+		devices: devices,
+		entries: map[deviceAuthKey]deviceAuthEntry{},
+	}
+}
+
+// authenticate : DeviceCodeとCardSecretのヘッダを検証し、端末のIDをcontextに入れる
+func (a *deviceAuthenticator) authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	deviceCode := strings.TrimSpace(r.Header.Get(DeviceCode.String()))
+	cardSecret := r.Header.Get(CardSecret.String())
+	if deviceCode == "" || cardSecret == "" {
+		return ctx, errors.Wrap(errof.ErrAuthentication, "device code and card secret are required")
+	}
+
+	device, err := a.devices.Device(ctx, deviceCode)
+	if err != nil {
+		_ = bcrypt.CompareHashAndPassword(dummySecretHash(), []byte(cardSecret))
+		return ctx, err
+	}
+	key := deviceAuthKey{deviceCode, sha256.Sum256([]byte(cardSecret))}
+	if a.cached(key, device.SecretHash) {
+		return util.SetDeviceID(ctx, device.ID), nil
+	}
+	if err := bcrypt.CompareHashAndPassword(device.SecretHash, []byte(cardSecret)); err != nil {
+		return ctx, errors.Wrapf(errof.ErrAuthentication, "invalid card secret, device: %s", deviceCode)
+	}
+	a.store(key, device.SecretHash)
+	return util.SetDeviceID(ctx, device.ID), nil
+}
+
+func (a *deviceAuthenticator) cached(key deviceAuthKey, secretHash []byte) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.entries[key]
+	if !ok {
+		return false
+	}
+	if !util.TimeNowFunc().Before(entry.expiresAt) || !bytes.Equal(entry.secretHash, secretHash) {
+		delete(a.entries, key)
+		return false
+	}
+	return true
+}
+
+func (a *deviceAuthenticator) store(key deviceAuthKey, secretHash []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := util.TimeNowFunc()
+	if deviceAuthSweepSize < len(a.entries) {
+		for k, entry := range a.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(a.entries, k)
+			}
+		}
+	}
+	a.entries[key] = deviceAuthEntry{secretHash: secretHash, expiresAt: now.Add(deviceAuthTTL)}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testSecretHash(t *testing.T, secret string) []byte {
+	t.Helper()
+	// テストではDefaultCostだと遅いためMinCostにする
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func authenticateTestDevice(a *deviceAuthenticator, deviceCode, cardSecret string) (context.Context, error) {
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set(DeviceCode.String(), deviceCode)
+	r.Header.Set(CardSecret.String(), cardSecret)
+	return a.authenticate(context.Background(), r)
+}
+
+func TestDeviceAuthenticate(t *testing.T) {
+	store := NewMemoryDeviceStore(Device{ID: "device-1", Code: "code-1", SecretHash: testSecretHash(t, "secret")})
+	tests := []struct {
+		name       string
+		deviceCode string
+		cardSecret string
+		wantErr    error
+	}{
+		{name: "valid", deviceCode: "code-1", cardSecret: "secret"},
+		{name: "wrong secret", deviceCode: "code-1", cardSecret: "other", wantErr: errof.ErrAuthentication},
+		{name: "unknown device", deviceCode: "code-2", cardSecret: "secret", wantErr: errof.ErrAuthentication},
+		{name: "no secret", deviceCode: "code-1", wantErr: errof.ErrAuthentication},
+		{name: "no device code", cardSecret: "secret", wantErr: errof.ErrAuthentication},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := authenticateTestDevice(newDeviceAuthenticator(store), tt.deviceCode, tt.cardSecret)
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && util.GetDeviceID(ctx) != "device-1" {
+				t.Errorf("GetDeviceID() = %q, want device-1", util.GetDeviceID(ctx))
+			}
+		})
+	}
+}
+
+func TestDeviceAuthenticateCache(t *testing.T) {
+	store := NewMemoryDeviceStore(Device{ID: "device-1", Code: "code-1", SecretHash: testSecretHash(t, "secret")})
+	a := newDeviceAuthenticator(store)
+
+	for i := 0; i < 2; i++ {
+		if _, err := authenticateTestDevice(a, "code-1", "secret"); err != nil {
+			t.Fatalf("authenticate() error = %v", err)
+		}
+	}
+	if len(a.entries) != 1 {
+		t.Fatalf("len(entries) = %d, want 1", len(a.entries))
+	}
+	// 失敗した組み合わせはキャッシュしない
+	if _, err := authenticateTestDevice(a, "code-1", "other"); errors.Cause(err) != errof.ErrAuthentication {
+		t.Fatalf("authenticate() error = %v, want %v", err, errof.ErrAuthentication)
+	}
+	if len(a.entries) != 1 {
+		t.Errorf("len(entries) = %d, want 1", len(a.entries))
+	}
+
+	// CardSecretを変更した場合、キャッシュされた古いCardSecretは使えない
+	store.Add(Device{ID: "device-1", Code: "code-1", SecretHash: testSecretHash(t, "rotated")})
+	if _, err := authenticateTestDevice(a, "code-1", "secret"); errors.Cause(err) != errof.ErrAuthentication {
+		t.Fatalf("authenticate() error = %v, want %v", err, errof.ErrAuthentication)
+	}
+	if _, err := authenticateTestDevice(a, "code-1", "rotated"); err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+}
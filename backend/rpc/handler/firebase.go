@@ -3,118 +3,97 @@ package handler
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/httptest/backend/pkg/config"
 	"github.com/httptest/backend/pkg/errof"
-	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/firebase"
+	"github.com/httptest/backend/pkg/ratelimit"
 	"github.com/httptest/backend/pkg/util"
 	"github.com/httptest/backend/rpc/usecase"
-	"github.com/inconshreveable/log15"
 	"github.com/pkg/errors"
 )
 
-type firebaseHandler struct {
-	allowOrigin string
-	funcMap     map[string]Func
+// NewFirebaseHandler : funcMapが不正な場合はエラーを返す
+func NewFirebaseHandler(
+	c config.HTTP,
+	verifier firebase.Verifier,
+	roles RoleResolver,
+	orgs OrgResolver,
+	devices DeviceStore,
+	rateLimiter ratelimit.Store,
+	successUsecase usecase.Success,
+) (http.Handler, error) {
+	return newFirebaseHandler(c, verifier, roles, orgs, devices, rateLimiter, successUsecase)
 }
 
-// NewFirebaseHandler :
-func NewFirebaseHandler(
+func newFirebaseHandler(
 	c config.HTTP,
+	verifier firebase.Verifier,
+	roles RoleResolver,
+	orgs OrgResolver,
+	devices DeviceStore,
+	rateLimiter ratelimit.Store,
 	successUsecase usecase.Success,
-) http.Handler {
-	return firebaseHandler{
-		c.Cors,
+) (rpcHandler, error) {
+	return newRPCHandler(
+		c,
 		GetFirebaseFuncMap(
 			successUsecase,
 		),
-	}
+		firebaseAuthenticator{verifier, roles, orgs, newDeviceAuthenticator(devices)},
+		rateLimiter,
+	)
 }
 
-func (h firebaseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var err error
-	writeSecurityHeaders(w)
-	writeCORSHeaders(w, h.allowOrigin)
-	if preflightCheck(w, r, true) {
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
+// GetFirebaseFuncMap :
+func GetFirebaseFuncMap(
+	successUsecase usecase.Success,
+) map[string]Func {
+	return map[string]Func{
+		"getSuccess": RegisterNoParams("成功", successUsecase.GetSuccess),
 	}
+}
 
-	// ctx := r.Context()
-	ctx := context.Background()
-	returnsCh := make(chan []*jsonrpc.Return, 1)
-	go func() {
-		sourceIps := r.Header.Values(XForwardedFor.String())
-		if 0 < len(sourceIps) {
-			ctx = util.SetIPAddress(ctx, sourceIps[len(sourceIps)-1])
-		}
-
-		requests, errs := jsonrpc.Parse(r)
-		if errs != nil {
-			returnsCh <- handleReturn(ctx, nil, nil, err)
-			return
-		}
-
-		// rpcを呼ぶ時、空の配列だった場合の処理
-		if len(requests) == 0 {
-			returnsCh <- handleReturn(ctx, nil, nil, errors.Wrap(errof.ErrParse, "empty request"))
-			return
-		}
-
-		authorization := r.Header.Get(Authorization.String())
-		if authorization == "" {
-			returnsCh <- handleReturn(ctx, nil, nil, errof.ErrInvalidRequest)
-			return
-		}
-
-		var returns []*jsonrpc.Return
-		for _, request := range requests {
-			result, err := h.Exec(ctx, request.Method, request.Params)
-			returns = append(returns, handleReturn(ctx, request.ID, result, err)...)
-		}
-		returnsCh <- returns
-	}()
+// firebaseAuthenticator : AuthorizationヘッダのIDトークンを検証する
+// OrgCodeヘッダがある場合は、そのオーガニゼーションに所属しているかも確認する
+// DeviceCodeヘッダがある場合は、IDトークンの代わりに端末として認証する
+type firebaseAuthenticator struct {
+	verifier firebase.Verifier
+	roles    RoleResolver
+	orgs     OrgResolver
+	devices  *deviceAuthenticator
+}
 
-	select {
-	case r := <-returnsCh:
-		if err = jsonrpc.WriteResponses(w, r...); err != nil {
-			log15.Crit("Failed to write success response ", "err", err, "request", r)
-			return
-		}
-		return
-		//	case <-ctx.Done():
-		//		_ = jsonrpc.WriteResponses(w, handleReturn(ctx, nil, nil, jsonrpc.ErrInternal())...)
-		//		return
+func (a firebaseAuthenticator) Authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	if r.Header.Get(DeviceCode.String()) != "" {
+		return a.devices.authenticate(ctx, r)
+	}
+	idToken := bearerToken(r)
+	if idToken == "" {
+		return ctx, errors.WithStack(errof.ErrInvalidRequest)
+	}
+	token, err := a.verifier.Verify(ctx, idToken)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = util.SetCredential(ctx, token.Credential)
+	ctx = util.SetUserID(ctx, token.Credential.UID)
+	ctx = util.SetTokenExpiry(ctx, token.ExpiresAt)
+	roles, err := a.roles.Roles(ctx, token)
+	if err != nil {
+		return ctx, err
 	}
+	ctx = util.SetRoles(ctx, roles)
+	return resolveOrg(ctx, r, a.orgs)
 }
 
-func (h *firebaseHandler) Exec(ctx context.Context, methodName string, params []byte) (result interface{}, err error) {
-	defer func() {
-		if p := recover(); p != nil {
-			err = errors.Wrap(errof.ErrInternal, errof.PanicToErr(p).Error())
-		}
-		if err != nil {
-			err = errors.Wrapf(err, "Method Front Failed methodName: %s, params: %s", methodName, string(params))
-		}
-		go func() {
-			ctx = util.GetWithoutCancelContext(ctx)
-		}()
-	}()
-	if f, ok := h.funcMap[methodName]; ok {
-		return f.Call(ctx, params)
-	}
-	return nil, errors.WithStack(errof.ErrMethodNotFound)
+func (firebaseAuthenticator) RequireAuthorization() bool {
+	return true
 }
 
-// GetFirebaseFuncMap :
-func GetFirebaseFuncMap(
-	successUsecase usecase.Success,
-) map[string]Func {
-	return map[string]Func{
-		"getSuccess": {Name: "成功", Method: successUsecase.GetSuccess},
-	}
+// bearerToken : "Bearer "がない場合はヘッダの値をそのままトークンとする
+func bearerToken(r *http.Request) string {
+	authorization := strings.TrimSpace(r.Header.Get(Authorization.String()))
+	return strings.TrimSpace(strings.TrimPrefix(authorization, bearerPrefix))
 }
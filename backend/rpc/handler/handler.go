@@ -7,10 +7,14 @@ import (
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/friendsofgo/errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/httptest/backend/pkg/config"
 	"github.com/httptest/backend/pkg/errof"
 	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/ratelimit"
 	"github.com/httptest/backend/pkg/util"
 	"github.com/inconshreveable/log15"
 )
@@ -21,11 +25,32 @@ func (h Header) String() string {
 	return string(h)
 }
 
-// Func :
+// Func : Register, RegisterNoParamsで生成する
 type Func struct {
-	Name        string
-	Method      interface{}
+	Name string
+	// Method : Registerを使わずに指定した場合は、呼び出し毎にreflectionで呼び出す
+	Method interface{}
+	// Permissions : いずれかのロールを持つ呼び出し元のみ実行できる、空の場合は誰でも実行できる
 	Permissions []string
+	// Serial : trueの場合、バッチ内で他のメソッドと並列に実行しない
+	Serial bool
+	// Timeout : 0の場合はconfig.HTTP.MethodTimeout
+	// ctxの期限として渡すため、期限を過ぎてもメソッドがctxに従って戻るまでは終わらない
+	Timeout time.Duration
+	// RateLimit : config.HTTP.RateLimitとは別に、呼び出し元毎のこのメソッドの呼び出し数の上限
+	RateLimit ratelimit.Limit
+
+	// deviceOnly : 端末として認証された呼び出し元のみ実行できる
+	deviceOnly bool
+
+	call       func(ctx context.Context, paramJSON []byte) (interface{}, error)
+	paramsType reflect.Type
+	resultType reflect.Type
+	// positional : 配列のparamsをparamsTypeのフィールドの宣言順に割り当てる
+	positional bool
+	// argTypes, paramNames : Register2で複数の引数に割り当てる場合
+	argTypes   []reflect.Type
+	paramNames []string
 }
 
 const (
@@ -34,10 +59,15 @@ const (
 	DeviceCode    Header = "DeviceCode"
 	Authorization Header = "Authorization"
 	XForwardedFor Header = "X-Forwarded-For"
+	XRequestID    Header = "X-Request-ID"
 )
 
 // Call :
 func (f Func) Call(ctx context.Context, paramJSON []byte) (result interface{}, err error) {
+	if f.call != nil {
+		return f.call(ctx, paramJSON)
+	}
+
 	// func(context.Context, input.AddLotCount) error
 	args := []reflect.Value{
 		reflect.ValueOf(ctx),
@@ -49,11 +79,11 @@ func (f Func) Call(ctx context.Context, paramJSON []byte) (result interface{}, e
 		inputType := funcType.In(1)
 		params := reflect.New(inputType).Interface()
 		if err := json.Unmarshal(paramJSON, params); err != nil {
-			return nil, errors.Wrapf(errof.ErrParse, err.Error())
+			return nil, errors.Wrap(errof.ErrInvalidParams, err.Error())
 		}
 		if inputType.Kind() != reflect.Slice {
 			if err = validate.Struct(params); err != nil {
-				return nil, errors.Wrapf(errof.ErrInvalidParams, "input :%+v, err: %s", inputType.String(), err)
+				return nil, invalidParams(err, inputType)
 			}
 		}
 		// indirectを使って、値を参照する
@@ -81,11 +111,43 @@ func (f Func) Call(ctx context.Context, paramJSON []byte) (result interface{}, e
 	}
 }
 
+// invalidParams : validatorのエラーをフィールド毎のエラーにする
+func invalidParams(err error, inputType reflect.Type) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return errors.Wrapf(errof.ErrInvalidParams, "input :%+v, err: %s", inputType.String(), err)
+	}
+
+	fields := make([]jsonrpc.FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		// Namespaceの先頭はstructの型名なので除く
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); 0 <= i {
+			field = field[i+1:]
+		}
+		fields = append(fields, jsonrpc.FieldError{
+			Field:   field,
+			Tag:     fe.Tag(),
+			Param:   fe.Param(),
+			Message: errof.ValidationMessage(fe.Tag(), fe.Param(), errof.DefaultLang),
+		})
+	}
+	return errors.Wrapf(&jsonrpc.InvalidParamsError{Fields: fields}, "input :%+v", inputType.String())
+}
+
+func newLimits(c config.HTTP) jsonrpc.Limits {
+	return jsonrpc.Limits{
+		MaxBodyBytes: c.MaxBodyBytes,
+		MaxBatchSize: c.MaxBatchSize,
+		MaxDepth:     c.MaxDepth,
+	}
+}
+
 func writeCORSHeaders(w http.ResponseWriter, allowOrigin string) {
 	header := w.Header()
 	header.Set("Access-Control-Allow-Origin", allowOrigin)
 	header.Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-	header.Set("Access-Control-Allow-Headers", fmt.Sprintf("Content-Type, %s, %s", Authorization, OrgCode))
+	header.Set("Access-Control-Allow-Headers", fmt.Sprintf("Content-Type, %s, %s, %s, %s", Authorization, OrgCode, DeviceCode, CardSecret))
 	header.Set("Access-Control-Allow-Credentials", "true")
 	header.Set("Access-Control-Max-Age", "86400")
 	header.Set("Content-Type", "application/json; charset=utf-8")
@@ -102,9 +164,10 @@ func writeSecurityHeaders(w http.ResponseWriter) {
 
 func preflightCheck(w http.ResponseWriter, r *http.Request, needAuthorization bool) bool {
 	if r.Method == http.MethodOptions {
-		s := r.Header.Get("Access-Control-Request-Headers")
+		s := strings.ToLower(r.Header.Get("Access-Control-Request-Headers"))
 		if needAuthorization {
-			if strings.Contains(s, "authorization") || strings.Contains(s, "Authorization") {
+			// 端末はAuthorizationの代わりにDeviceCodeで認証する
+			if strings.Contains(s, "authorization") || strings.Contains(s, "devicecode") {
 				w.WriteHeader(http.StatusNoContent)
 			} else {
 				w.WriteHeader(http.StatusBadRequest)
@@ -117,26 +180,42 @@ func preflightCheck(w http.ResponseWriter, r *http.Request, needAuthorization bo
 	return false
 }
 
-func handleReturn(ctx context.Context, id, result interface{}, err error) (returns []*jsonrpc.Return) {
+// warnErrs : 呼び出し元に起因するエラー、ErrorではなくWarnでログを残す
+var warnErrs = []error{
+	errof.ErrAuthentication,
+	errof.ErrExpired,
+	errof.ErrPermissionDenied,
+	errof.ErrNoOrg,
+	errof.ErrNotOrgMember,
+	errof.ErrRateLimited,
+}
+
+// handleReturn : requestがnilの場合はリクエスト全体に対するエラーとして扱う
+func handleReturn(ctx context.Context, request *jsonrpc.Request, result interface{}, err error) (returns []*jsonrpc.Return) {
 	if ctx.Err() == context.Canceled {
 		return nil
 	}
 
-	r := &jsonrpc.Return{ID: id, Result: result}
+	r := &jsonrpc.Return{Result: result}
+	if request != nil {
+		r.ID = request.ID
+		// 通知の場合もエラーのログは残すが、レスポンスには含めない
+		r.Notification = request.IsNotification()
+	}
 	if err == nil {
 		return []*jsonrpc.Return{r}
 	}
 
 	cause := errors.Cause(err)
 	var skipLog bool
-	for _, originErr := range []error{errof.ErrAuthentication} {
+	for _, originErr := range warnErrs {
 		if cause == originErr {
-			log15.Warn(cause.Error(), "err", strings.Replace(fmt.Sprintf("%+v", err), "'", "*", -1))
+			log15.Warn(cause.Error(), "request_id", util.GetRequestID(ctx), "err", strings.Replace(fmt.Sprintf("%+v", err), "'", "*", -1))
 			skipLog = true
 		}
 	}
 	if !skipLog {
-		log15.Error(cause.Error(), "err", strings.Replace(fmt.Sprintf("%+v", err), "'", "*", -1))
+		log15.Error(cause.Error(), "request_id", util.GetRequestID(ctx), "err", strings.Replace(fmt.Sprintf("%+v", err), "'", "*", -1))
 	}
 
 	switch cause {
@@ -145,6 +224,9 @@ func handleReturn(ctx context.Context, id, result interface{}, err error) (retur
 			err = errors.Wrap(errof.ErrTooLongParameter, err.Error())
 			log15.Error(cause.Error(), "err", fmt.Sprintf("%+v", err))
 			r.Error = jsonrpc.ErrTooLongParameter()
+		} else {
+			// データベースのエラーの内容は返さない
+			r.Error = jsonrpc.ErrInternal()
 		}
 	case errof.ErrParse:
 		r.Error = jsonrpc.ErrParse()
@@ -153,11 +235,39 @@ func handleReturn(ctx context.Context, id, result interface{}, err error) (retur
 	case errof.ErrMethodNotFound:
 		r.Error = jsonrpc.ErrMethodNotFound()
 	case errof.ErrInvalidParams:
-		r.Error = jsonrpc.ErrInvalidParams()
+		var paramsErr *jsonrpc.InvalidParamsError
+		if errors.As(err, &paramsErr) {
+			r.Error = jsonrpc.ErrInvalidParams(paramsErr.Fields...)
+		} else {
+			r.Error = jsonrpc.ErrInvalidParams()
+		}
+	case errof.ErrRequestTooLarge, errof.ErrBatchTooLarge, errof.ErrNestingTooDeep:
+		var limitErr *jsonrpc.LimitError
+		if errors.As(err, &limitErr) {
+			r.Error = jsonrpc.ErrLimitExceeded(limitErr)
+		} else {
+			r.Error = jsonrpc.ErrInvalidRequest()
+		}
+	case errof.ErrPermissionDenied:
+		r.Error = jsonrpc.ErrPermissionDenied()
+	case errof.ErrNotOrgMember:
+		r.Error = jsonrpc.ErrNotOrgMember()
+	case errof.ErrTimeout:
+		r.Error = jsonrpc.ErrTimeout()
+	case errof.ErrRateLimited:
+		var rateLimitErr *jsonrpc.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			r.Error = jsonrpc.ErrRateLimited(rateLimitErr)
+		} else {
+			r.Error = jsonrpc.ErrRateLimited(&jsonrpc.RateLimitError{})
+		}
 	case errof.ErrInternal:
 		r.Error = jsonrpc.ErrInternal()
 	default:
 		r.Error = jsonrpc.ErrServer(cause)
 	}
+	if r.Error != nil {
+		r.Error.Localize(util.GetLang(ctx))
+	}
 	return []*jsonrpc.Return{r}
 }
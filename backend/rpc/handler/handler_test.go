@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+)
+
+func TestHandleReturnError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode jsonrpc.ErrorCode
+	}{
+		{name: "database", err: errors.Wrap(errof.ErrDatabase, "connection refused"), wantCode: jsonrpc.ErrorCodeInternal},
+		{name: "value too long", err: errors.Wrap(errof.ErrDatabase, "pq: value too long for type character varying(8)"), wantCode: jsonrpc.ErrorCodeInvalidParams},
+		{name: "internal", err: errors.Wrap(errof.ErrInternal, "x"), wantCode: jsonrpc.ErrorCodeInternal},
+		{name: "invalid params", err: errors.Wrap(errof.ErrInvalidParams, "x"), wantCode: jsonrpc.ErrorCodeInvalidParams},
+		{name: "timeout", err: errors.Wrap(errof.ErrTimeout, "x"), wantCode: jsonrpc.ErrorCodeTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			returns := handleReturn(context.Background(), &jsonrpc.Request{ID: 1}, nil, tt.err)
+			if len(returns) != 1 || returns[0].Error == nil {
+				t.Fatalf("returns = %+v, want an error", returns)
+			}
+			if got := returns[0].Error.Code; got != tt.wantCode {
+				t.Errorf("code = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/db"
+	"github.com/httptest/backend/pkg/firebase"
+	"github.com/httptest/backend/pkg/health"
+)
+
+// NewHealthChecker : /readyzで確認する依存先を登録する
+func NewHealthChecker(
+	c config.Health,
+	verifier firebase.Verifier,
+	database *db.DB,
+) *health.Checker {
+	checker := health.NewChecker(c.Timeout)
+	checker.Register("firebase", verifier.Ready)
+	checker.Register("postgres", database.Ping)
+	return checker
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/ratelimit"
+	"github.com/httptest/backend/rpc/usecase"
+	"github.com/pkg/errors"
+)
+
+const bearerPrefix = "Bearer "
+
+// NewInternalHandler : 社内のサービスから呼び出すエンドポイント
+func NewInternalHandler(
+	c config.HTTP,
+	ic config.Internal,
+	rateLimiter ratelimit.Store,
+	successUsecase usecase.Success,
+) (http.Handler, error) {
+	return newRPCHandler(
+		c,
+		GetInternalFuncMap(
+			successUsecase,
+		),
+		internalAuthenticator{ic.Credential},
+		rateLimiter,
+	)
+}
+
+// GetInternalFuncMap :
+func GetInternalFuncMap(
+	successUsecase usecase.Success,
+) map[string]Func {
+	return map[string]Func{
+		"getSuccess": RegisterNoParams("成功", successUsecase.GetSuccess),
+	}
+}
+
+// internalAuthenticator : Authorizationヘッダの Bearer <credential> を検証する
+type internalAuthenticator struct {
+	credential string
+}
+
+func (a internalAuthenticator) Authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	// credentialが設定されていない場合は全て拒否する
+	if a.credential == "" {
+		return ctx, errors.Wrap(errof.ErrAuthentication, "internal credential is not configured")
+	}
+	authorization := r.Header.Get(Authorization.String())
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return ctx, errors.Wrap(errof.ErrAuthentication, "bearer token is required")
+	}
+	token := strings.TrimPrefix(authorization, bearerPrefix)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.credential)) != 1 {
+		return ctx, errors.Wrap(errof.ErrAuthentication, "invalid credential")
+	}
+	return ctx, nil
+}
+
+func (internalAuthenticator) RequireAuthorization() bool {
+	return true
+}
@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/rpc/usecase"
+	"github.com/inconshreveable/log15"
+)
+
+const (
+	openRPCVersion = "1.2.6"
+	openRPCTitle   = "httptest rpc"
+	apiVersion     = "1.0.0"
+
+	// DiscoverMethod : OpenRPCで予約されているメソッド名
+	DiscoverMethod = "rpc.discover"
+)
+
+// OpenRPC : https://spec.open-rpc.org/
+type OpenRPC struct {
+	OpenRPC string          `json:"openrpc"`
+	Info    OpenRPCInfo     `json:"info"`
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+// OpenRPCInfo :
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod :
+type OpenRPCMethod struct {
+	Name           string              `json:"name"`
+	Summary        string              `json:"summary,omitempty"`
+	ParamStructure string              `json:"paramStructure,omitempty"`
+	Params         []ContentDescriptor `json:"params"`
+	Result         *ContentDescriptor  `json:"result,omitempty"`
+	Permissions    []string            `json:"x-permissions,omitempty"`
+	DeviceOnly     bool                `json:"x-device-only,omitempty"`
+}
+
+// ContentDescriptor :
+type ContentDescriptor struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Schema : JSON Schemaのうち、Goの型とvalidateタグから表現できるもの
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+
+	// order : Propertiesのフィールドの宣言順
+	order []string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// NewOpenRPCDocument : funcMapの各Funcの型からOpenRPCのドキュメントを生成する
+func NewOpenRPCDocument(funcMap map[string]Func) *OpenRPC {
+	doc := &OpenRPC{
+		OpenRPC: openRPCVersion,
+		Info:    OpenRPCInfo{Title: openRPCTitle, Version: apiVersion},
+		Methods: []OpenRPCMethod{},
+	}
+	for name, f := range funcMap {
+		doc.Methods = append(doc.Methods, newOpenRPCMethod(name, f))
+	}
+	sort.Slice(doc.Methods, func(i, j int) bool {
+		return doc.Methods[i].Name < doc.Methods[j].Name
+	})
+	return doc
+}
+
+func newOpenRPCMethod(name string, f Func) OpenRPCMethod {
+	m := OpenRPCMethod{
+		Name:           name,
+		Summary:        f.Name,
+		ParamStructure: "by-name",
+		Params:         []ContentDescriptor{},
+		Permissions:    f.Permissions,
+		DeviceOnly:     f.deviceOnly,
+	}
+	paramsType, resultType := f.types()
+
+	// struct以外のparams ([]string、intなど) は1つのパラメータとする
+	if paramsType != nil && !isStruct(paramsType) {
+		m.ParamStructure = "by-position"
+		m.Params = append(m.Params, ContentDescriptor{
+			Name:     "params",
+			Required: paramsType.Kind() != reflect.Ptr,
+			Schema:   newSchema(paramsType, map[reflect.Type]bool{}),
+		})
+	}
+
+	// paramsのstructのフィールドをパラメータとする
+	if paramsType != nil && isStruct(paramsType) {
+		params := newSchema(paramsType, map[reflect.Type]bool{})
+		for _, name := range params.order {
+			m.Params = append(m.Params, ContentDescriptor{
+				Name:     name,
+				Required: contains(params.Required, name),
+				Schema:   params.Properties[name],
+			})
+		}
+		if f.positional {
+			m.ParamStructure = "either"
+		}
+	}
+
+	// Register2の場合は引数をそのままパラメータとする
+	if f.argTypes != nil {
+		m.ParamStructure = "by-position"
+		for i, argType := range f.argTypes {
+			name := fmt.Sprintf("arg%d", i)
+			if i < len(f.paramNames) {
+				name = f.paramNames[i]
+			}
+			m.Params = append(m.Params, ContentDescriptor{
+				Name:   name,
+				Schema: newSchema(argType, map[reflect.Type]bool{}),
+			})
+		}
+	}
+
+	// 返り値がerrorのみの場合はresultなし
+	if resultType != nil {
+		m.Result = &ContentDescriptor{
+			Name:   "result",
+			Schema: newSchema(resultType, map[reflect.Type]bool{}),
+		}
+	}
+	return m
+}
+
+// newSchema : seenは再帰的な型で無限ループしないためのもの
+func newSchema(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	// volatiletech/nullの型は中身の値の型として扱う
+	if t.Kind() == reflect.Struct && strings.HasPrefix(t.PkgPath(), "github.com/volatiletech/null") && 0 < t.NumField() {
+		return newSchema(t.Field(0).Type, seen)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: newSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: newSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return &Schema{Type: "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addProperties(s, t, seen)
+		return s
+	}
+	// interface{}など
+	return &Schema{}
+}
+
+func addProperties(s *Schema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+		// 埋め込みのstructはフィールドを展開する
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addProperties(s, ft, seen)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fs := newSchema(field.Type, seen)
+		if applyValidateTag(fs, field.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+		s.order = append(s.order, name)
+	}
+}
+
+// jsonFieldName : jsonタグの名前を返す、タグがない場合は空文字
+func jsonFieldName(field reflect.StructField) (name string, ok bool) {
+	if field.PkgPath != "" && !field.Anonymous {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	return strings.Split(tag, ",")[0], true
+}
+
+// applyValidateTag : validateタグの制約をSchemaに反映し、requiredかどうかを返す
+func applyValidateTag(s *Schema, tag string) (required bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		kv := strings.SplitN(rule, "=", 2)
+		key, param := kv[0], ""
+		if len(kv) == 2 {
+			param = kv[1]
+		}
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "min", "gte":
+			applyBound(s, param, true)
+		case "max", "lte":
+			applyBound(s, param, false)
+		case "len":
+			applyBound(s, param, true)
+			applyBound(s, param, false)
+		}
+	}
+	return required
+}
+
+// applyBound : 型によってmin/maxの意味が変わる (文字数、要素数、値)
+func applyBound(s *Schema, param string, lower bool) {
+	switch s.Type {
+	case "string", "array":
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &n
+		case s.Type == "string":
+			s.MaxLength = &n
+		case lower:
+			s.MinItems = &n
+		default:
+			s.MaxItems = &n
+		}
+	case "integer", "number":
+		f, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		if lower {
+			s.Minimum = &f
+		} else {
+			s.Maximum = &f
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverFunc : rpc.discoverとして登録するFunc
+func discoverFunc(funcMap map[string]Func) Func {
+	doc := NewOpenRPCDocument(funcMap)
+	return RegisterNoParams("API定義の取得", func(ctx context.Context) (*OpenRPC, error) {
+		return doc, nil
+	})
+}
+
+type openRPCHandler struct {
+	allowOrigin string
+	doc         *OpenRPC
+}
+
+// NewOpenRPCHandler : GETでOpenRPCのドキュメントを返す
+func NewOpenRPCHandler(
+	c config.HTTP,
+	successUsecase usecase.Success,
+) http.Handler {
+	return openRPCHandler{
+		c.Cors,
+		NewOpenRPCDocument(GetFirebaseFuncMap(successUsecase)),
+	}
+}
+
+func (h openRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeSecurityHeaders(w)
+	writeCORSHeaders(w, h.allowOrigin)
+	if preflightCheck(w, r, false) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(h.doc); err != nil {
+		log15.Crit("Failed to write openrpc document", "err", err)
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type openRPCParams struct {
+	Name  string `json:"name" validate:"required,max=8"`
+	Count int    `json:"count" validate:"min=1"`
+}
+
+func TestNewOpenRPCMethod(t *testing.T) {
+	tests := []struct {
+		name string
+		f    Func
+		// want : paramStructureとparamsのJSON
+		wantStructure string
+		wantParams    string
+	}{
+		{
+			name:          "struct",
+			f:             Register("登録", echoParams),
+			wantStructure: "by-name",
+			wantParams:    `[{"name":"name","required":true,"schema":{"type":"string"}},{"name":"count","schema":{"type":"integer"}}]`,
+		},
+		{
+			name:          "positional struct",
+			f:             Register("登録", func(ctx context.Context, p openRPCParams) (string, error) { return p.Name, nil }, WithPositional()),
+			wantStructure: "either",
+			wantParams:    `[{"name":"name","required":true,"schema":{"type":"string","maxLength":8}},{"name":"count","schema":{"type":"integer","minimum":1}}]`,
+		},
+		{
+			name:          "slice",
+			f:             Register("一覧", func(ctx context.Context, ids []string) (int, error) { return len(ids), nil }),
+			wantStructure: "by-position",
+			wantParams:    `[{"name":"params","required":true,"schema":{"type":"array","items":{"type":"string"}}}]`,
+		},
+		{
+			name:          "int",
+			f:             Register("数値", func(ctx context.Context, n int) (int, error) { return n, nil }),
+			wantStructure: "by-position",
+			wantParams:    `[{"name":"params","required":true,"schema":{"type":"integer"}}]`,
+		},
+		{
+			name:          "map",
+			f:             Register("連想配列", func(ctx context.Context, m map[string]int) (int, error) { return len(m), nil }),
+			wantStructure: "by-position",
+			wantParams:    `[{"name":"params","required":true,"schema":{"type":"object","additionalProperties":{"type":"integer"}}}]`,
+		},
+		{
+			name:          "args",
+			f:             Register2("登録", echoArgs, WithParamNames("prefix", "params")),
+			wantStructure: "by-position",
+			wantParams:    `[{"name":"prefix","schema":{"type":"string"}},{"name":"params","schema":{"type":"object","properties":{"count":{"type":"integer"},"name":{"type":"string"}},"required":["name"]}}]`,
+		},
+		{
+			name:          "no params",
+			f:             RegisterNoParams("取得", func(ctx context.Context) (string, error) { return "", nil }),
+			wantStructure: "by-name",
+			wantParams:    `[]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOpenRPCMethod("method", tt.f)
+			if m.ParamStructure != tt.wantStructure {
+				t.Errorf("ParamStructure = %q, want %q", m.ParamStructure, tt.wantStructure)
+			}
+			params, err := json.Marshal(m.Params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(params) != tt.wantParams {
+				t.Errorf("Params = %s\nwant %s", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestNewOpenRPCDocument(t *testing.T) {
+	doc := NewOpenRPCDocument(map[string]Func{
+		"b": Register("一覧", func(ctx context.Context, ids []string) (int, error) { return len(ids), nil }),
+		"a": RegisterNoParams("取得", func(ctx context.Context) (string, error) { return "", nil }),
+	})
+	if len(doc.Methods) != 2 || doc.Methods[0].Name != "a" || doc.Methods[1].Name != "b" {
+		t.Fatalf("Methods = %+v, want a, b", doc.Methods)
+	}
+	if r := doc.Methods[1].Result; r == nil || r.Schema.Type != "integer" {
+		t.Errorf("Result = %+v, want integer", r)
+	}
+}
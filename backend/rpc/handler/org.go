@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// OrgResolver : OrgCodeヘッダのオーガニゼーションを解決する
+type OrgResolver interface {
+	// Resolve : orgCodeのオーガニゼーションのIDを返す、存在しない場合はerrof.ErrNoOrg
+	Resolve(ctx context.Context, orgCode string) (orgID string, err error)
+	// IsMember : userIDがオーガニゼーションに所属しているか
+	IsMember(ctx context.Context, orgID, userID string) (bool, error)
+}
+
+// Org : MemoryOrgResolverに登録するオーガニゼーション
+type Org struct {
+	ID      string
+	Code    string
+	Members []string
+}
+
+// MemoryOrgResolver : メモリ上のOrgから解決する
+type MemoryOrgResolver struct {
+	mu      sync.RWMutex
+	byCode  map[string]Org
+	members map[string]map[string]struct{}
+}
+
+// NewOrgResolver :
+// DBなどから解決する場合は、OrgResolverを実装したものに差し替える
+func NewOrgResolver() OrgResolver {
+	return NewMemoryOrgResolver()
+}
+
+// NewMemoryOrgResolver :
+func NewMemoryOrgResolver(orgs ...Org) *MemoryOrgResolver {
+	r := &MemoryOrgResolver{
+		byCode:  map[string]Org{},
+		members: map[string]map[string]struct{}{},
+	}
+	for _, org := range orgs {
+		r.Add(org)
+	}
+	return r
+}
+
+// Add : 同じCodeのOrgは上書きする
+func (r *MemoryOrgResolver) Add(org Org) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byCode[org.Code] = org
+	members := make(map[string]struct{}, len(org.Members))
+	for _, userID := range org.Members {
+		members[userID] = struct{}{}
+	}
+	r.members[org.ID] = members
+}
+
+func (r *MemoryOrgResolver) Resolve(_ context.Context, orgCode string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	org, ok := r.byCode[orgCode]
+	if !ok {
+		return "", errors.Wrapf(errof.ErrNoOrg, "orgCode: %s", orgCode)
+	}
+	return org.ID, nil
+}
+
+func (r *MemoryOrgResolver) IsMember(_ context.Context, orgID, userID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.members[orgID][userID]
+	return ok, nil
+}
+
+// resolveOrg : OrgCodeヘッダがある場合、認証済みのユーザが所属しているか確認してcontextに入れる
+func resolveOrg(ctx context.Context, r *http.Request, orgs OrgResolver) (context.Context, error) {
+	orgCode := strings.TrimSpace(r.Header.Get(OrgCode.String()))
+	if orgCode == "" {
+		return ctx, nil
+	}
+	orgID, err := orgs.Resolve(ctx, orgCode)
+	if err != nil {
+		return ctx, err
+	}
+	userID := util.GetUserID(ctx)
+	ok, err := orgs.IsMember(ctx, orgID, userID)
+	if err != nil {
+		return ctx, err
+	}
+	if !ok {
+		return ctx, errors.Wrapf(errof.ErrNotOrgMember, "orgCode: %s, userID: %s", orgCode, userID)
+	}
+	return util.SetOrgID(ctx, orgID), nil
+}
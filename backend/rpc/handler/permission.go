@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"sort"
+
+	"github.com/httptest/backend/pkg/firebase"
+	"github.com/httptest/backend/pkg/util"
+)
+
+// AnyRole : RoleMethodsで、Permissionsがなく誰でも実行できるメソッドのキー
+const AnyRole = "*"
+
+// RoleResolver : 認証済みのユーザのロールを返す
+type RoleResolver interface {
+	Roles(ctx context.Context, token *firebase.Token) ([]string, error)
+}
+
+// claimRoleResolver : カスタムクレームのrolesをロールとする
+type claimRoleResolver struct{}
+
+// NewRoleResolver : カスタムクレームからロールを取り出す
+// DBなどからロールを引く場合は、RoleResolverを実装したものに差し替える
+func NewRoleResolver() RoleResolver {
+	return claimRoleResolver{}
+}
+
+func (claimRoleResolver) Roles(_ context.Context, token *firebase.Token) ([]string, error) {
+	return token.Roles(), nil
+}
+
+// permitted : 呼び出し元がPermissionsのいずれかのロールを持つか
+// 端末はWithDeviceOnlyのメソッドのみ、それ以外はWithDeviceOnly以外のメソッドのみ実行できる
+func (f Func) permitted(ctx context.Context) bool {
+	if f.deviceOnly != (util.GetDeviceID(ctx) != "") {
+		return false
+	}
+	if len(f.Permissions) == 0 {
+		return true
+	}
+	for _, role := range util.GetRoles(ctx) {
+		if contains(f.Permissions, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleMethods : ロール毎に実行できるメソッド名を返す (監査用)
+// Permissionsがないメソッドは全てのロールで実行できるため、AnyRoleにまとめる
+func RoleMethods(funcMap map[string]Func) map[string][]string {
+	roleMethods := map[string][]string{}
+	for method, f := range funcMap {
+		if len(f.Permissions) == 0 {
+			roleMethods[AnyRole] = append(roleMethods[AnyRole], method)
+			continue
+		}
+		for _, role := range f.Permissions {
+			roleMethods[role] = append(roleMethods[role], method)
+		}
+	}
+	for _, methods := range roleMethods {
+		sort.Strings(methods)
+	}
+	return roleMethods
+}
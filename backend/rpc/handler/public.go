@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/ratelimit"
+	"github.com/httptest/backend/rpc/usecase"
+)
+
+// NewPublicHandler : 認証なしで呼び出せるエンドポイント (サインアップ、ステータスなど)
+func NewPublicHandler(
+	c config.HTTP,
+	rateLimiter ratelimit.Store,
+	successUsecase usecase.Success,
+) (http.Handler, error) {
+	return newRPCHandler(
+		c,
+		GetPublicFuncMap(
+			successUsecase,
+		),
+		publicAuthenticator{},
+		rateLimiter,
+	)
+}
+
+// GetPublicFuncMap :
+func GetPublicFuncMap(
+	successUsecase usecase.Success,
+) map[string]Func {
+	return map[string]Func{
+		"getSuccess": RegisterNoParams("成功", successUsecase.GetSuccess),
+	}
+}
+
+// publicAuthenticator : 認証を行わない
+type publicAuthenticator struct{}
+
+func (publicAuthenticator) Authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	return ctx, nil
+}
+
+func (publicAuthenticator) RequireAuthorization() bool {
+	return false
+}
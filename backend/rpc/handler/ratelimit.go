@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/ratelimit"
+	"github.com/httptest/backend/pkg/util"
+	"github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+)
+
+// rateLimitKey : 端末、ユーザ、IPアドレスの順に呼び出し元を識別する
+func rateLimitKey(ctx context.Context) string {
+	if deviceID := util.GetDeviceID(ctx); deviceID != "" {
+		return "device:" + deviceID
+	}
+	if userID := util.GetUserID(ctx); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + util.GetIPAddress(ctx)
+}
+
+// allow : key毎にn回分の呼び出しを許可するか
+// Storeのエラーで呼び出しを止めないよう、エラーの場合は許可する
+func (h *rpcHandler) allow(ctx context.Context, key string, limit ratelimit.Limit, n int) error {
+	return h.limit(ctx, h.rateLimiter.Allow, key, limit, n)
+}
+
+// check : allowと同じ判定をトークンを取らずに行う
+func (h *rpcHandler) check(ctx context.Context, key string, limit ratelimit.Limit, n int) error {
+	return h.limit(ctx, h.rateLimiter.Check, key, limit, n)
+}
+
+func (h *rpcHandler) limit(
+	ctx context.Context,
+	take func(context.Context, string, ratelimit.Limit, int) (bool, time.Duration, error),
+	key string,
+	limit ratelimit.Limit,
+	n int,
+) error {
+	if !limit.Enabled() {
+		return nil
+	}
+	ok, retryAfter, err := take(ctx, key, limit, n)
+	if err != nil {
+		log15.Error("Failed to check rate limit", "key", key, "err", err)
+		return nil
+	}
+	if !ok {
+		return errors.Wrapf(&jsonrpc.RateLimitError{RetryAfter: retryAfter}, "key: %s", key)
+	}
+	return nil
+}
+
+// allowRequest : バッチの呼び出し数分をconfig.HTTP.RateLimitから取る
+func (h *rpcHandler) allowRequest(ctx context.Context, n int) error {
+	return h.allow(ctx, rateLimitKey(ctx), h.rateLimit, n)
+}
+
+// allowAuth : 認証を行うエンドポイントでは、認証の前にIPアドレス毎のconfig.HTTP.AuthRateLimitを確認する
+// 同じIPアドレスの利用者が上限を共有しないよう、トークンは認証に失敗した場合のみfailAuthで取る
+func (h *rpcHandler) allowAuth(ctx context.Context) error {
+	if !h.auth.RequireAuthorization() {
+		return nil
+	}
+	return h.check(ctx, authRateLimitKey(ctx), h.authRateLimit, 1)
+}
+
+// failAuth : 認証に失敗したリクエストをIPアドレス毎に数える
+func (h *rpcHandler) failAuth(ctx context.Context) {
+	if !h.auth.RequireAuthorization() {
+		return
+	}
+	// 上限に達していても認証のエラーを返すため、結果は使わない
+	_ = h.allow(ctx, authRateLimitKey(ctx), h.authRateLimit, 1)
+}
+
+func authRateLimitKey(ctx context.Context) string {
+	return "auth:ip:" + util.GetIPAddress(ctx)
+}
+
+// allowMethod : Func.RateLimitはメソッド毎に数える
+func (h *rpcHandler) allowMethod(ctx context.Context, methodName string, f Func) error {
+	return h.allow(ctx, methodName+":"+rateLimitKey(ctx), f.RateLimit, 1)
+}
+
+// rateLimitError : リクエスト全体がレート制限された場合のエラー
+func rateLimitError(err error) (*jsonrpc.RateLimitError, bool) {
+	var rateLimitErr *jsonrpc.RateLimitError
+	if errors.Cause(err) != errof.ErrRateLimited || !errors.As(err, &rateLimitErr) {
+		return nil, false
+	}
+	return rateLimitErr, true
+}
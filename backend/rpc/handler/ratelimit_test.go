@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/ratelimit"
+)
+
+const rateLimitTestBody = `{"jsonrpc":"2.0","method":"sleep","id":1}`
+
+func TestRateLimitSharedStore(t *testing.T) {
+	// 同じStoreを使うハンドラはIPアドレス毎の上限を共有する
+	store := ratelimit.NewStore()
+	c := config.HTTP{Cors: "*", Concurrency: 1, RateLimit: config.RateLimit{Rate: 0.001, Burst: 2}}
+	handlers := make([]rpcHandler, 2)
+	for i := range handlers {
+		funcMap := map[string]Func{"sleep": Register("待つ", sleepFunc(&concurrencyCounter{}, false))}
+		h, err := newRPCHandler(c, funcMap, publicAuthenticator{}, store)
+		if err != nil {
+			t.Fatalf("newRPCHandler() error = %v", err)
+		}
+		handlers[i] = h
+	}
+
+	for i, h := range handlers {
+		if w := serve(h, rateLimitTestBody); w.Code != http.StatusOK {
+			t.Fatalf("handlers[%d] status = %d, want %d", i, w.Code, http.StatusOK)
+		}
+	}
+	w := serve(handlers[0], rateLimitTestBody)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After = %q, want %d", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	if r := decodeReturn(t, w); r.Error == nil || r.Error.Code != jsonrpc.ErrorCodeRateLimited {
+		t.Errorf("error = %+v, want code %d", r.Error, jsonrpc.ErrorCodeRateLimited)
+	}
+}
+
+func TestRateLimitBeforeAuthentication(t *testing.T) {
+	// 認証に失敗し続ける呼び出し元は、認証の前に止める
+	c := config.HTTP{Cors: "*", Concurrency: 1, AuthRateLimit: config.RateLimit{Rate: 0.001, Burst: 3}}
+	funcMap := map[string]Func{"sleep": Register("待つ", sleepFunc(&concurrencyCounter{}, false))}
+	h, err := newRPCHandler(c, funcMap, internalAuthenticator{credential: "secret"}, ratelimit.NewStore())
+	if err != nil {
+		t.Fatalf("newRPCHandler() error = %v", err)
+	}
+
+	// 認証に成功したリクエストはAuthRateLimitを取らない
+	for i := 0; i < 10; i++ {
+		w := serveWithAuthorization(h, rateLimitTestBody, "Bearer secret")
+		if r := decodeReturn(t, w); w.Code != http.StatusOK || r.Error != nil {
+			t.Fatalf("authenticated requests[%d] status = %d, error = %+v", i, w.Code, r.Error)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		w := serve(h, rateLimitTestBody)
+		if r := decodeReturn(t, w); w.Code != http.StatusOK || r.Error == nil || r.Error.Code == jsonrpc.ErrorCodeRateLimited {
+			t.Fatalf("requests[%d] status = %d, error = %+v, want authentication error", i, w.Code, r.Error)
+		}
+	}
+	w := serve(h, rateLimitTestBody)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if r := decodeReturn(t, w); r.Error == nil || r.Error.Code != jsonrpc.ErrorCodeRateLimited {
+		t.Errorf("error = %+v, want code %d", r.Error, jsonrpc.ErrorCodeRateLimited)
+	}
+	// 上限に達した後は、同じIPアドレスの認証情報を持つリクエストも止める
+	if w := serveWithAuthorization(h, rateLimitTestBody, "Bearer secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("authenticated status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func serveWithAuthorization(h http.Handler, body, authorization string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set(Authorization.String(), authorization)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
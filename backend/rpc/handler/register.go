@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/friendsofgo/errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/ratelimit"
+	"github.com/httptest/backend/pkg/util"
+)
+
+// FuncOption :
+type FuncOption func(*Func)
+
+// WithPermissions :
+func WithPermissions(permissions ...string) FuncOption {
+	return func(f *Func) {
+		f.Permissions = permissions
+	}
+}
+
+// WithSerial : バッチ内で他のメソッドと並列に実行しない
+func WithSerial() FuncOption {
+	return func(f *Func) {
+		f.Serial = true
+	}
+}
+
+// WithDeviceOnly : 端末として認証された呼び出し元のみ実行できる
+// 端末はWithDeviceOnlyのメソッド以外は実行できない
+func WithDeviceOnly() FuncOption {
+	return func(f *Func) {
+		f.deviceOnly = true
+	}
+}
+
+// WithTimeout : config.HTTP.MethodTimeoutの代わりにこのメソッドのタイムアウトを指定する
+// メソッドはctxを受け取る処理 (DBなど) に渡し、期限で戻るようにすること
+func WithTimeout(timeout time.Duration) FuncOption {
+	return func(f *Func) {
+		f.Timeout = timeout
+	}
+}
+
+// WithRateLimit : 呼び出し元毎に1秒あたりrate回、最大burst回まで続けて呼び出せる
+func WithRateLimit(rate float64, burst int) FuncOption {
+	return func(f *Func) {
+		f.RateLimit = ratelimit.Limit{Rate: rate, Burst: burst}
+	}
+}
+
+// WithPositional : 配列のparamsをstructのフィールドの宣言順に割り当てる
+// オブジェクトのparamsもこれまで通り受け付ける
+func WithPositional() FuncOption {
+	return func(f *Func) {
+		f.positional = true
+	}
+}
+
+// WithParamNames : Register2の引数の名前 (OpenRPCで使う)
+func WithParamNames(names ...string) FuncOption {
+	return func(f *Func) {
+		f.paramNames = names
+	}
+}
+
+// Register : paramsをInにデコード、バリデーションしてmethodを呼び出すFuncを生成する
+// 型はコンパイル時に決まるため、呼び出し毎のreflectionは行わない
+func Register[In, Out any](name string, method func(context.Context, In) (Out, error), opts ...FuncOption) Func {
+	f := Func{
+		Name:       name,
+		paramsType: typeOf[In](),
+		resultType: typeOf[Out](),
+	}
+	for _, opt := range opts {
+		opt(&f)
+	}
+
+	paramsType := f.paramsType
+	validate := util.NewValidator()
+	needValidate := isStruct(paramsType)
+	var fields []string
+	if f.positional {
+		fields = positionalFields(paramsType)
+	}
+	f.call = func(ctx context.Context, paramJSON []byte) (interface{}, error) {
+		var in In
+		if err := decodeParams(paramJSON, fields, &in); err != nil {
+			return nil, err
+		}
+		if needValidate {
+			// paramsを省略した場合もrequiredのフィールドをエラーにする
+			if err := validateStruct(validate, reflect.ValueOf(&in).Elem(), paramsType, true); err != nil {
+				return nil, err
+			}
+		}
+		return method(ctx, in)
+	}
+	return f
+}
+
+// Register2 : 配列のparamsを順に2つの引数へデコードしてmethodを呼び出すFuncを生成する
+func Register2[A, B, Out any](name string, method func(context.Context, A, B) (Out, error), opts ...FuncOption) Func {
+	f := Func{
+		Name:       name,
+		argTypes:   []reflect.Type{typeOf[A](), typeOf[B]()},
+		resultType: typeOf[Out](),
+	}
+	for _, opt := range opts {
+		opt(&f)
+	}
+
+	argTypes := f.argTypes
+	validate := util.NewValidator()
+	f.call = func(ctx context.Context, paramJSON []byte) (interface{}, error) {
+		var a A
+		var b B
+		args := []interface{}{&a, &b}
+		if err := decodeArgs(paramJSON, args...); err != nil {
+			return nil, err
+		}
+		for i, arg := range args {
+			if !isStruct(argTypes[i]) {
+				continue
+			}
+			// nullを渡した引数は検証しない
+			if err := validateStruct(validate, reflect.ValueOf(arg).Elem(), argTypes[i], false); err != nil {
+				return nil, err
+			}
+		}
+		return method(ctx, a, b)
+	}
+	return f
+}
+
+// RegisterNoParams : paramsを受け取らないmethodのFuncを生成する
+func RegisterNoParams[Out any](name string, method func(context.Context) (Out, error), opts ...FuncOption) Func {
+	f := Func{
+		Name: name,
+		call: func(ctx context.Context, _ []byte) (interface{}, error) {
+			return method(ctx)
+		},
+		resultType: typeOf[Out](),
+	}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// decodeParams : fieldsがある場合、配列のparamsはfieldsの名前のオブジェクトとしてデコードする
+// paramsが省略された場合はゼロ値のままにする
+// paramsはParseで正しいJSONか確認済みのため、デコードできないのは型が違う場合としてErrInvalidParamsを返す
+func decodeParams(paramJSON []byte, fields []string, v interface{}) error {
+	if len(paramJSON) == 0 {
+		return nil
+	}
+	if fields != nil && isArray(paramJSON) {
+		var values []json.RawMessage
+		if err := json.Unmarshal(paramJSON, &values); err != nil {
+			return errors.Wrap(errof.ErrInvalidParams, err.Error())
+		}
+		if len(fields) < len(values) {
+			return errors.Wrapf(errof.ErrInvalidParams, "too many params: %d, max: %d", len(values), len(fields))
+		}
+		obj := make(map[string]json.RawMessage, len(values))
+		for i, value := range values {
+			obj[fields[i]] = value
+		}
+		var err error
+		if paramJSON, err = json.Marshal(obj); err != nil {
+			return errors.Wrap(errof.ErrInvalidParams, err.Error())
+		}
+	}
+	if err := json.Unmarshal(paramJSON, v); err != nil {
+		return errors.Wrap(errof.ErrInvalidParams, err.Error())
+	}
+	return nil
+}
+
+// decodeArgs : 配列のparamsを順にargsへデコードする、足りない引数はゼロ値のまま
+func decodeArgs(paramJSON []byte, args ...interface{}) error {
+	if len(paramJSON) == 0 {
+		return nil
+	}
+	if !isArray(paramJSON) {
+		return errors.Wrap(errof.ErrInvalidParams, "params must be an array")
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(paramJSON, &values); err != nil {
+		return errors.Wrap(errof.ErrInvalidParams, err.Error())
+	}
+	if len(args) < len(values) {
+		return errors.Wrapf(errof.ErrInvalidParams, "too many params: %d, max: %d", len(values), len(args))
+	}
+	for i, value := range values {
+		if err := json.Unmarshal(value, args[i]); err != nil {
+			return errors.Wrap(errof.ErrInvalidParams, err.Error())
+		}
+	}
+	return nil
+}
+
+// positionalFields : structのjsonでのフィールド名を宣言順に返す、埋め込みのstructは展開する
+func positionalFields(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+		if field.Anonymous && name == "" && isStruct(field.Type) {
+			fields = append(fields, positionalFields(field.Type)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+func isArray(paramJSON []byte) bool {
+	trimmed := bytes.TrimLeft(paramJSON, " \t\r\n")
+	return 0 < len(trimmed) && trimmed[0] == '['
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// types : paramsとresultの型、ない場合はnil
+func (f Func) types() (paramsType, resultType reflect.Type) {
+	if f.call != nil {
+		return f.paramsType, f.resultType
+	}
+
+	funcType := reflect.TypeOf(f.Method)
+	if funcType == nil || funcType.Kind() != reflect.Func {
+		return nil, nil
+	}
+	if 1 < funcType.NumIn() {
+		paramsType = funcType.In(1)
+	}
+	if 1 < funcType.NumOut() {
+		resultType = funcType.Out(0)
+	}
+	return paramsType, resultType
+}
+
+// validateStruct : ポインタの場合は参照先のstructを検証する
+// nilの場合、validateNilならstructの零値を検証し、そうでなければ検証しない
+func validateStruct(validate *validator.Validate, v reflect.Value, t reflect.Type, validateNil bool) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !validateNil {
+				return nil
+			}
+			v = reflect.New(v.Type().Elem())
+		}
+		v = v.Elem()
+	}
+	if err := validate.Struct(v.Interface()); err != nil {
+		return invalidParams(err, t)
+	}
+	return nil
+}
+
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/friendsofgo/errors"
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+)
+
+type registerParams struct {
+	Name  string `json:"name" validate:"required"`
+	Count int    `json:"count"`
+}
+
+func echoParams(ctx context.Context, p registerParams) (registerParams, error) {
+	return p, nil
+}
+
+func echoArgs(ctx context.Context, name string, p registerParams) (string, error) {
+	return name + p.Name, nil
+}
+
+func TestRegisterCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       Func
+		params  string
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name:   "object",
+			f:      Register("登録", echoParams),
+			params: `{"name":"a","count":1}`,
+			want:   registerParams{"a", 1},
+		},
+		{
+			name:   "positional",
+			f:      Register("登録", echoParams, WithPositional()),
+			params: `["a",1]`,
+			want:   registerParams{"a", 1},
+		},
+		{
+			name:    "wrong field type",
+			f:       Register("登録", echoParams),
+			params:  `{"name":"a","count":"1"}`,
+			wantErr: errof.ErrInvalidParams,
+		},
+		{
+			name:    "array without positional",
+			f:       Register("登録", echoParams),
+			params:  `["a",1]`,
+			wantErr: errof.ErrInvalidParams,
+		},
+		{
+			name:    "positional with wrong type",
+			f:       Register("登録", echoParams, WithPositional()),
+			params:  `["a","1"]`,
+			wantErr: errof.ErrInvalidParams,
+		},
+		{
+			name:    "too many positional params",
+			f:       Register("登録", echoParams, WithPositional()),
+			params:  `["a",1,2]`,
+			wantErr: errof.ErrInvalidParams,
+		},
+		{
+			name:    "validation",
+			f:       Register("登録", echoParams),
+			params:  `{"count":1}`,
+			wantErr: errof.ErrInvalidParams,
+		},
+		{
+			name:   "args",
+			f:      Register2("登録", echoArgs),
+			params: `["a",{"name":"b"}]`,
+			want:   "ab",
+		},
+		{
+			name:    "args of wrong type",
+			f:       Register2("登録", echoArgs),
+			params:  `[1,{"name":"b"}]`,
+			wantErr: errof.ErrInvalidParams,
+		},
+		{
+			name:    "args not an array",
+			f:       Register2("登録", echoArgs),
+			params:  `{"name":"b"}`,
+			wantErr: errof.ErrInvalidParams,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.f.Call(context.Background(), []byte(tt.params))
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("Call() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("Call() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func echoPointerArgs(ctx context.Context, p *registerParams, n int) (string, error) {
+	if p == nil {
+		return "nil", nil
+	}
+	return p.Name, nil
+}
+
+func echoPointerParams(ctx context.Context, p *registerParams) (string, error) {
+	return p.Name, nil
+}
+
+func TestRegisterPointerParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		f      Func
+		params string
+		want   interface{}
+		// wantFields : requiredのエラーになるフィールド
+		wantFields []string
+	}{
+		{name: "args", f: Register2("登録", echoPointerArgs), params: `[{"name":"a"},1]`, want: "a"},
+		{name: "null arg", f: Register2("登録", echoPointerArgs), params: `[null,1]`, want: "nil"},
+		{name: "args validation", f: Register2("登録", echoPointerArgs), params: `[{"count":1},1]`, wantFields: []string{"name"}},
+		{name: "params", f: Register("登録", echoPointerParams), params: `{"name":"a"}`, want: "a"},
+		{name: "params validation", f: Register("登録", echoPointerParams), params: `{"count":1}`, wantFields: []string{"name"}},
+		{name: "params omitted", f: Register("登録", echoPointerParams), params: ``, wantFields: []string{"name"}},
+		{name: "null params", f: Register("登録", echoPointerParams), params: `null`, wantFields: []string{"name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.f.Call(context.Background(), []byte(tt.params))
+			if tt.wantFields == nil {
+				if err != nil || got != tt.want {
+					t.Fatalf("Call() = %+v, %v, want %+v", got, err, tt.want)
+				}
+				return
+			}
+			var paramsErr *jsonrpc.InvalidParamsError
+			if !errors.As(err, &paramsErr) {
+				t.Fatalf("Call() error = %v, want *jsonrpc.InvalidParamsError", err)
+			}
+			if len(paramsErr.Fields) != len(tt.wantFields) {
+				t.Fatalf("Fields = %+v, want %v", paramsErr.Fields, tt.wantFields)
+			}
+			for i, field := range tt.wantFields {
+				if paramsErr.Fields[i].Field != field || paramsErr.Fields[i].Tag != "required" {
+					t.Errorf("Fields[%d] = %+v, want %s required", i, paramsErr.Fields[i], field)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterInvalidParamsCode(t *testing.T) {
+	// Registerとreflectionのどちらで呼び出しても、型の合わないparamsは-32602にする
+	h := newTestHandler(t, config.HTTP{}, map[string]Func{
+		"echo":    Register("登録", echoParams),
+		"reflect": {Name: "登録 (reflection)", Method: echoParams},
+	})
+	tests := []struct {
+		name     string
+		body     string
+		wantCode jsonrpc.ErrorCode
+	}{
+		{name: "register", body: `{"jsonrpc":"2.0","method":"echo","params":{"name":1},"id":1}`, wantCode: jsonrpc.ErrorCodeInvalidParams},
+		{name: "reflection", body: `{"jsonrpc":"2.0","method":"reflect","params":{"name":1},"id":1}`, wantCode: jsonrpc.ErrorCodeInvalidParams},
+		{name: "reflection validation", body: `{"jsonrpc":"2.0","method":"reflect","params":{"count":1},"id":1}`, wantCode: jsonrpc.ErrorCodeInvalidParams},
+		{name: "invalid json", body: `{"jsonrpc":"2.0","method":"reflect","params":{"name":`, wantCode: jsonrpc.ErrorCodeParse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := decodeReturn(t, serve(h, tt.body))
+			if r.Error == nil || r.Error.Code != tt.wantCode {
+				t.Errorf("error = %+v, want code %d", r.Error, tt.wantCode)
+			}
+		})
+	}
+}
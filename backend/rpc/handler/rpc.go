@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/metrics"
+	"github.com/httptest/backend/pkg/ratelimit"
+	"github.com/httptest/backend/pkg/util"
+	"github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+)
+
+// Authenticator : エンドポイント毎の認証方式
+type Authenticator interface {
+	// Authenticate : 認証に成功した場合は、認証情報を入れたcontextを返す
+	Authenticate(ctx context.Context, r *http.Request) (context.Context, error)
+	// RequireAuthorization : preflightでAuthorizationヘッダを必須とするか
+	RequireAuthorization() bool
+}
+
+// rpcHandler : エンドポイント毎にfuncMapと認証方式を持つ、呼び出しやエラー処理は共通
+type rpcHandler struct {
+	allowOrigin   string
+	concurrency   int
+	methodTimeout time.Duration
+	limits        jsonrpc.Limits
+	funcMap       map[string]Func
+	auth          Authenticator
+	ips           *util.IPResolver
+	rateLimit     ratelimit.Limit
+	authRateLimit ratelimit.Limit
+	rateLimiter   ratelimit.Store
+}
+
+// statusClientClosedRequest : レスポンスを返す前にクライアントが切断した場合のアクセスログのステータス (nginxと同じ)
+const statusClientClosedRequest = 499
+
+// response : rateLimitedの場合は429を返す
+type response struct {
+	returns []*jsonrpc.Return
+	// batch : バッチのリクエストを実行した結果か、リクエスト全体のエラーはfalse
+	batch       bool
+	rateLimited *jsonrpc.RateLimitError
+	// ctx, calls : アクセスログ用、ctxは認証後のもの
+	ctx   context.Context
+	calls int
+}
+
+// newRPCHandler : funcMapが不正な場合はエラーを返す
+func newRPCHandler(c config.HTTP, funcMap map[string]Func, auth Authenticator, rateLimiter ratelimit.Store) (rpcHandler, error) {
+	if err := VerifyFuncMap(funcMap); err != nil {
+		return rpcHandler{}, err
+	}
+	ips, err := util.NewIPResolver(c.TrustedProxies, c.ForwardedHeader)
+	if err != nil {
+		return rpcHandler{}, err
+	}
+	funcMap[DiscoverMethod] = discoverFunc(funcMap)
+	return rpcHandler{
+		c.Cors,
+		c.Concurrency,
+		c.MethodTimeout,
+		newLimits(c),
+		funcMap,
+		auth,
+		ips,
+		ratelimit.FromConfig(c.RateLimit),
+		ratelimit.FromConfig(c.AuthRateLimit),
+		rateLimiter,
+	}, nil
+}
+
+func (h rpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	writeSecurityHeaders(w)
+	writeCORSHeaders(w, h.allowOrigin)
+	if preflightCheck(w, r, h.auth.RequireAuthorization()) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+
+	// クライアントが切断した場合はctxがキャンセルされ、実行中のメソッドにも伝わる
+	ctx, span := startRequestSpan(r.Context(), r)
+	defer span.End()
+	ctx = util.SetRequestID(ctx, requestID(r))
+	w.Header().Set(XRequestID.String(), util.GetRequestID(ctx))
+	ctx = util.SetLang(ctx, errof.MatchLang(r.Header.Get("Accept-Language")))
+	ctx = util.SetIPAddress(ctx, h.ips.ClientIP(r))
+
+	// bodyの読み込みも含めてハンドラの中で終わらせる (ServeHTTPから戻った後はr.Bodyを読めない)
+	res := h.serve(ctx, r)
+	if ctx.Err() != nil {
+		// クライアントは既に切断しているため、レスポンスは書き込まない
+		log15.Warn("Client disconnected", "err", ctx.Err())
+		logRequest(res.ctx, r, statusClientClosedRequest, false, start, "calls", res.calls)
+		return
+	}
+	defer func() {
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := status < http.StatusBadRequest && callCode(res.returns) == 0
+		logRequest(res.ctx, r, status, success, start, "calls", res.calls)
+	}()
+	if res.rateLimited != nil {
+		w.Header().Set("Retry-After", strconv.FormatInt(res.rateLimited.RetryAfterSeconds(), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	if err = jsonrpc.WriteResponses(w, res.batch, res.returns...); err != nil {
+		log15.Crit("Failed to write success response ", "err", err, "request", res.returns)
+		return
+	}
+}
+
+// serve : パース、認証、レート制限の後にバッチを実行する
+// 認証に失敗し続けるIPアドレスは、認証の前に止める
+func (h *rpcHandler) serve(ctx context.Context, r *http.Request) response {
+	if err := h.allowAuth(ctx); err != nil {
+		rateLimited, _ := rateLimitError(err)
+		return response{returns: handleReturn(ctx, nil, nil, err), rateLimited: rateLimited, ctx: ctx}
+	}
+
+	requests, batch, err := jsonrpc.Parse(r, h.limits)
+	if err != nil {
+		return response{returns: handleReturn(ctx, nil, nil, err), ctx: ctx}
+	}
+
+	if ctx, err = h.auth.Authenticate(ctx, r); err != nil {
+		h.failAuth(ctx)
+		return response{returns: handleReturn(ctx, nil, nil, err), ctx: ctx, calls: len(requests)}
+	}
+
+	if err = h.allowRequest(ctx, len(requests)); err != nil {
+		rateLimited, _ := rateLimitError(err)
+		return response{returns: handleReturn(ctx, nil, nil, err), rateLimited: rateLimited, ctx: ctx, calls: len(requests)}
+	}
+
+	return response{returns: h.execBatch(ctx, requests), batch: batch, ctx: ctx, calls: len(requests)}
+}
+
+// execBatch : バッチの各リクエストを並列に実行し、リクエスト順に結果を返す
+func (h *rpcHandler) execBatch(ctx context.Context, requests []*jsonrpc.Request) (returns []*jsonrpc.Return) {
+	metrics.InFlightRequests.Inc()
+	defer metrics.InFlightRequests.Dec()
+	metrics.BatchSize.Observe(float64(len(requests)))
+
+	results := make([][]*jsonrpc.Return, len(requests))
+	exec := func(i int) {
+		request := requests[i]
+		// クライアントが切断した後は実行しない
+		if ctx.Err() != nil {
+			return
+		}
+		start := time.Now()
+		method := h.metricsMethod(request.Method)
+		callCtx, span := startCallSpan(ctx, i, method)
+		metrics.InFlightCalls.Inc()
+		if request.Err != nil {
+			results[i] = handleReturn(callCtx, request, nil, request.Err)
+		} else {
+			result, err := h.Exec(callCtx, request.Method, request.Params)
+			results[i] = handleReturn(callCtx, request, result, err)
+		}
+		metrics.InFlightCalls.Dec()
+		code := callCode(results[i])
+		endCallSpan(span, code)
+		// クライアントが切断した場合は数えない
+		if 0 < len(results[i]) {
+			metrics.ObserveCall(method, code, time.Since(start))
+		}
+		logCall(callCtx, i, request.Method, start, results[i])
+	}
+
+	concurrency := h.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		if ctx.Err() != nil {
+			break
+		}
+		// Serialなメソッドは実行中のものが全て終わってから単独で実行する
+		if f, ok := h.funcMap[request.Method]; ok && f.Serial {
+			wg.Wait()
+			exec(i)
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			exec(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		returns = append(returns, r...)
+	}
+	return returns
+}
+
+// Exec : メソッドをタイムアウト付きで実行する
+// タイムアウトはctxの期限として渡し、メソッドが戻るまで待つ (メソッドはctxに従って戻ること)
+// 期限を過ぎてエラーが返った場合はerrof.ErrTimeoutにする
+func (h *rpcHandler) Exec(ctx context.Context, methodName string, params []byte) (result interface{}, err error) {
+	defer func() {
+		if err != nil {
+			err = errors.Wrapf(err, "Method Front Failed methodName: %s, params: %s", methodName, string(params))
+		}
+		go func() {
+			ctx = util.GetWithoutCancelContext(ctx)
+		}()
+	}()
+	f, ok := h.funcMap[methodName]
+	if !ok {
+		return nil, errors.WithStack(errof.ErrMethodNotFound)
+	}
+	if !f.permitted(ctx) {
+		return nil, errors.Wrapf(errof.ErrPermissionDenied, "required: %v, roles: %v", f.Permissions, util.GetRoles(ctx))
+	}
+	if err := h.allowMethod(ctx, methodName, f); err != nil {
+		return nil, err
+	}
+
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = h.methodTimeout
+	}
+	if 0 < timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err = call(ctx, methodName, f, params)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, errors.Wrapf(errof.ErrTimeout, "timeout: %s, err: %s", timeout, err)
+	}
+	return result, err
+}
+
+// call : メソッドのpanicはerrof.ErrInternalにする
+func call(ctx context.Context, methodName string, f Func, params []byte) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			metrics.Panics.WithLabelValues(methodName).Inc()
+			result, err = nil, errors.Wrap(errof.ErrInternal, errof.PanicToErr(p).Error())
+		}
+	}()
+	return f.Call(ctx, params)
+}
+
+// metricsMethod : funcMapにないメソッド名はmetrics.UnknownMethodにまとめる
+func (h *rpcHandler) metricsMethod(methodName string) string {
+	if _, ok := h.funcMap[methodName]; ok {
+		return methodName
+	}
+	return metrics.UnknownMethod
+}
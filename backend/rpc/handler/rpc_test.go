@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/ratelimit"
+)
+
+type sleepParams struct {
+	N       int `json:"n"`
+	SleepMS int `json:"sleep_ms"`
+}
+
+// concurrencyCounter : 同時に実行中の呼び出し数の最大値を記録する
+type concurrencyCounter struct {
+	mu      sync.Mutex
+	running int
+	max     int
+	// serialOverlap : Serialなメソッドが他の呼び出しと並行して実行された回数
+	serialOverlap int
+}
+
+func (c *concurrencyCounter) enter(serial bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if serial && 0 < c.running {
+		c.serialOverlap++
+	}
+	c.running++
+	if c.max < c.running {
+		c.max = c.running
+	}
+}
+
+func (c *concurrencyCounter) leave() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+}
+
+func sleepFunc(counter *concurrencyCounter, serial bool) func(ctx context.Context, p sleepParams) (int, error) {
+	return func(ctx context.Context, p sleepParams) (int, error) {
+		counter.enter(serial)
+		defer counter.leave()
+		time.Sleep(time.Duration(p.SleepMS) * time.Millisecond)
+		return p.N, nil
+	}
+}
+
+func newTestHandler(t *testing.T, c config.HTTP, funcMap map[string]Func) rpcHandler {
+	t.Helper()
+	if c.Cors == "" {
+		c.Cors = "*"
+	}
+	h, err := newRPCHandler(c, funcMap, publicAuthenticator{}, ratelimit.NewStore())
+	if err != nil {
+		t.Fatalf("newRPCHandler() error = %v", err)
+	}
+	return h
+}
+
+func serve(h http.Handler, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+type testReturn struct {
+	ID     interface{}     `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *jsonrpc.Error  `json:"error"`
+}
+
+func decodeReturn(t *testing.T, w *httptest.ResponseRecorder) testReturn {
+	t.Helper()
+	var r testReturn
+	if err := json.Unmarshal(w.Body.Bytes(), &r); err != nil {
+		t.Fatalf("response is not an object: %s", w.Body.String())
+	}
+	return r
+}
+
+func decodeBatch(t *testing.T, w *httptest.ResponseRecorder) []testReturn {
+	t.Helper()
+	var returns []testReturn
+	if err := json.Unmarshal(w.Body.Bytes(), &returns); err != nil {
+		t.Fatalf("response is not an array: %s", w.Body.String())
+	}
+	return returns
+}
+
+func TestExecBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		// sleeps : 後のリクエストほど早く終わるようにして、順序が保たれるか確認する
+		sleeps []int
+		serial map[int]bool
+	}{
+		{name: "serial execution", concurrency: 0, sleeps: []int{30, 20, 10}},
+		{name: "concurrent", concurrency: 4, sleeps: []int{40, 30, 20, 10}},
+		{name: "bounded", concurrency: 2, sleeps: []int{40, 30, 20, 10, 5, 1}},
+		{name: "with serial method", concurrency: 4, sleeps: []int{30, 20, 10, 30, 20, 10}, serial: map[int]bool{3: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &concurrencyCounter{}
+			h := newTestHandler(t, config.HTTP{Concurrency: tt.concurrency}, map[string]Func{
+				"sleep":       Register("待つ", sleepFunc(counter, false)),
+				"sleepSerial": Register("単独で待つ", sleepFunc(counter, true), WithSerial()),
+			})
+
+			calls := make([]string, len(tt.sleeps))
+			for i, sleep := range tt.sleeps {
+				method := "sleep"
+				if tt.serial[i] {
+					method = "sleepSerial"
+				}
+				calls[i] = fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"params":{"n":%d,"sleep_ms":%d},"id":%d}`, method, i, sleep, i)
+			}
+			w := serve(h, "["+strings.Join(calls, ",")+"]")
+
+			returns := decodeBatch(t, w)
+			if len(returns) != len(tt.sleeps) {
+				t.Fatalf("len(returns) = %d, want %d", len(returns), len(tt.sleeps))
+			}
+			for i, r := range returns {
+				if r.Error != nil {
+					t.Fatalf("returns[%d].Error = %+v", i, r.Error)
+				}
+				if r.ID != float64(i) || string(r.Result) != fmt.Sprint(i) {
+					t.Errorf("returns[%d] = id: %v, result: %s, want %d", i, r.ID, r.Result, i)
+				}
+			}
+
+			limit := tt.concurrency
+			if limit < 1 {
+				limit = 1
+			}
+			if limit < counter.max {
+				t.Errorf("max concurrency = %d, want <= %d", counter.max, limit)
+			}
+			if counter.serialOverlap != 0 {
+				t.Errorf("serial method ran alongside %d calls", counter.serialOverlap)
+			}
+		})
+	}
+}
+
+func TestServeHTTPResponseShape(t *testing.T) {
+	counter := &concurrencyCounter{}
+	h := newTestHandler(t, config.HTTP{Concurrency: 2}, map[string]Func{
+		"sleep": Register("待つ", sleepFunc(counter, false)),
+	})
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		// wantArray : バッチの結果は1つでも配列で返す
+		wantArray bool
+		wantCode  jsonrpc.ErrorCode
+	}{
+		{
+			name:       "single",
+			body:       `{"jsonrpc":"2.0","method":"sleep","id":1}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "notification and call",
+			body:       `[{"jsonrpc":"2.0","method":"sleep"},{"jsonrpc":"2.0","method":"sleep","id":1}]`,
+			wantStatus: http.StatusOK,
+			wantArray:  true,
+		},
+		{
+			name:       "null id",
+			body:       `{"jsonrpc":"2.0","method":"sleep","id":null}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "only notifications",
+			body:       `[{"jsonrpc":"2.0","method":"sleep"},{"jsonrpc":"2.0","method":"sleep"}]`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "empty batch",
+			body:       `[]`,
+			wantStatus: http.StatusOK,
+			wantCode:   jsonrpc.ErrorCodeInvalidRequest,
+		},
+		{
+			name:       "parse error",
+			body:       `[{"jsonrpc":"2.0"`,
+			wantStatus: http.StatusOK,
+			wantCode:   jsonrpc.ErrorCodeParse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNoContent {
+				if w.Body.Len() != 0 {
+					t.Errorf("body = %s, want empty", w.Body.String())
+				}
+				return
+			}
+			body := strings.TrimSpace(w.Body.String())
+			if isArray := strings.HasPrefix(body, "["); isArray != tt.wantArray {
+				t.Fatalf("body = %s, want array: %v", body, tt.wantArray)
+			}
+			var r testReturn
+			if tt.wantArray {
+				r = decodeBatch(t, w)[0]
+			} else if err := json.Unmarshal([]byte(body), &r); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if tt.wantCode == 0 {
+				if r.Error != nil {
+					t.Errorf("error = %+v, want none", r.Error)
+				}
+				return
+			}
+			if r.Error == nil || r.Error.Code != tt.wantCode {
+				t.Errorf("error = %+v, want code %d", r.Error, tt.wantCode)
+			}
+		})
+	}
+}
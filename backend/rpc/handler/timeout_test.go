@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/jsonrpc"
+)
+
+type waitParams struct {
+	SleepMS int `json:"sleep_ms"`
+}
+
+// waitFunc : ctxが終わるかsleep_msが経つまで待つ、ctxが終わった場合はctxのエラーを返す
+func waitFunc(counter *concurrencyCounter) func(ctx context.Context, p waitParams) (string, error) {
+	return func(ctx context.Context, p waitParams) (string, error) {
+		counter.enter(false)
+		defer counter.leave()
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Duration(p.SleepMS) * time.Millisecond):
+			return "done", nil
+		}
+	}
+}
+
+// ignoreFunc : ctxに従わずsleep_ms待つ
+func ignoreFunc(counter *concurrencyCounter) func(ctx context.Context, p waitParams) (string, error) {
+	return func(ctx context.Context, p waitParams) (string, error) {
+		counter.enter(true)
+		defer counter.leave()
+		time.Sleep(time.Duration(p.SleepMS) * time.Millisecond)
+		return "done", nil
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	counter := &concurrencyCounter{}
+	h := newTestHandler(t, config.HTTP{Concurrency: 2, MethodTimeout: time.Second}, map[string]Func{
+		"wait":   Register("待つ", waitFunc(counter), WithTimeout(30*time.Millisecond)),
+		"ignore": Register("無視する", ignoreFunc(counter), WithTimeout(30*time.Millisecond), WithSerial()),
+	})
+	tests := []struct {
+		name     string
+		body     string
+		wantCode jsonrpc.ErrorCode
+	}{
+		{name: "in time", body: `{"jsonrpc":"2.0","method":"wait","params":{"sleep_ms":1},"id":1}`},
+		{name: "timeout", body: `{"jsonrpc":"2.0","method":"wait","params":{"sleep_ms":1000},"id":1}`, wantCode: jsonrpc.ErrorCodeTimeout},
+		// ctxに従わないメソッドは戻るまで待ち、結果をそのまま返す
+		{name: "ignores ctx", body: `{"jsonrpc":"2.0","method":"ignore","params":{"sleep_ms":60},"id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := decodeReturn(t, serve(h, tt.body))
+			if tt.wantCode == 0 {
+				if r.Error != nil || string(r.Result) != `"done"` {
+					t.Errorf("return = %s, %+v", r.Result, r.Error)
+				}
+				return
+			}
+			if r.Error == nil || r.Error.Code != tt.wantCode {
+				t.Errorf("error = %+v, want code %d", r.Error, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestExecBatchTimeoutHoldsSlots(t *testing.T) {
+	// タイムアウトしたメソッドが戻るまで次の呼び出しを始めない
+	counter := &concurrencyCounter{}
+	h := newTestHandler(t, config.HTTP{Concurrency: 2}, map[string]Func{
+		"wait":   Register("待つ", waitFunc(counter)),
+		"ignore": Register("無視する", ignoreFunc(counter), WithTimeout(10*time.Millisecond), WithSerial()),
+	})
+	w := serve(h, `[
+		{"jsonrpc":"2.0","method":"wait","params":{"sleep_ms":20},"id":1},
+		{"jsonrpc":"2.0","method":"ignore","params":{"sleep_ms":50},"id":2},
+		{"jsonrpc":"2.0","method":"wait","params":{"sleep_ms":20},"id":3},
+		{"jsonrpc":"2.0","method":"wait","params":{"sleep_ms":20},"id":4},
+		{"jsonrpc":"2.0","method":"wait","params":{"sleep_ms":20},"id":5}
+	]`)
+	if returns := decodeBatch(t, w); len(returns) != 5 {
+		t.Fatalf("len(returns) = %d, want 5", len(returns))
+	}
+	if 2 < counter.max {
+		t.Errorf("max concurrency = %d, want <= 2", counter.max)
+	}
+	if counter.serialOverlap != 0 {
+		t.Errorf("serial method ran alongside %d calls", counter.serialOverlap)
+	}
+}
+
+func TestServeHTTPClientDisconnect(t *testing.T) {
+	var started, canceled int32
+	h := newTestHandler(t, config.HTTP{Concurrency: 1}, map[string]Func{
+		"wait": Register("待つ", func(ctx context.Context, p waitParams) (string, error) {
+			atomic.AddInt32(&started, 1)
+			<-ctx.Done()
+			atomic.AddInt32(&canceled, 1)
+			return "", ctx.Err()
+		}),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[
+		{"jsonrpc":"2.0","method":"wait","id":1},
+		{"jsonrpc":"2.0","method":"wait","id":2},
+		{"jsonrpc":"2.0","method":"wait","id":3}
+	]`)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %s, want empty", w.Body.String())
+	}
+	// 切断した後のリクエストは実行しない
+	if s, c := atomic.LoadInt32(&started), atomic.LoadInt32(&canceled); s != 1 || c != 1 {
+		t.Errorf("started = %d, canceled = %d, want 1, 1", s, c)
+	}
+}
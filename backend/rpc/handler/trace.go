@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/httptest/backend/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRequestSpan : traceparentを引き継いでHTTPリクエストのspanを開始する
+func startRequestSpan(ctx context.Context, r *http.Request) (context.Context, trace.Span) {
+	ctx = tracing.Extract(ctx, r.Header)
+	return tracing.Tracer().Start(ctx, "jsonrpc "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.target", r.URL.Path),
+		),
+	)
+}
+
+// startCallSpan : 1回の呼び出しのspan、usecaseにはこのspanのctxを渡す
+func startCallSpan(ctx context.Context, index int, method string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", method),
+			attribute.Int("rpc.jsonrpc.batch_index", index),
+		),
+	)
+}
+
+// endCallSpan : codeは呼び出しのエラーコード、成功した場合は0
+func endCallSpan(span trace.Span, code int) {
+	span.SetAttributes(attribute.Int("rpc.jsonrpc.error_code", code))
+	if code != 0 {
+		span.SetStatus(codes.Error, "")
+	}
+	span.End()
+}
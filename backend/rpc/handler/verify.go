@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/friendsofgo/errors"
+)
+
+var (
+	contextType         = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType           = reflect.TypeOf((*error)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// VerifyFuncMap : 全てのFuncがFunc.Callで呼び出せる形か起動時に検証する
+func VerifyFuncMap(funcMap map[string]Func) error {
+	methods := make([]string, 0, len(funcMap))
+	for method := range funcMap {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	var msgs []string
+	names := map[string]string{}
+	for _, method := range methods {
+		f := funcMap[method]
+		if err := verifyFunc(method, f); err != nil {
+			msgs = append(msgs, fmt.Sprintf("%q: %s", method, err))
+		}
+		if other, ok := names[f.Name]; ok && f.Name != "" {
+			msgs = append(msgs, fmt.Sprintf("%q: duplicate name %q with %q", method, f.Name, other))
+		}
+		names[f.Name] = method
+	}
+	if 0 < len(msgs) {
+		return errors.Errorf("invalid func map: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func verifyFunc(method string, f Func) error {
+	if strings.TrimSpace(method) == "" {
+		return errors.New("empty method name")
+	}
+	// rpc.から始まるメソッド名は仕様で予約されている
+	if strings.HasPrefix(method, "rpc.") {
+		return errors.New("method names beginning with 'rpc.' are reserved")
+	}
+	if strings.TrimSpace(f.Name) == "" {
+		return errors.New("empty name")
+	}
+	if f.call == nil && f.Method == nil {
+		return errors.New("no method, use Register or set Method")
+	}
+	if f.call == nil {
+		if err := verifyMethod(f.Method); err != nil {
+			return err
+		}
+	}
+
+	paramsType, resultType := f.types()
+	if paramsType != nil {
+		if err := verifyJSONType(paramsType, true, map[reflect.Type]bool{}); err != nil {
+			return errors.Wrapf(err, "params %s is not decodable", paramsType)
+		}
+	}
+	if f.positional && (paramsType == nil || !isStruct(paramsType)) {
+		return errors.New("positional params require a struct params type")
+	}
+	for i, argType := range f.argTypes {
+		if err := verifyJSONType(argType, true, map[reflect.Type]bool{}); err != nil {
+			return errors.Wrapf(err, "argument %d %s is not decodable", i, argType)
+		}
+	}
+	if resultType != nil {
+		if err := verifyJSONType(resultType, false, map[reflect.Type]bool{}); err != nil {
+			return errors.Wrapf(err, "result %s is not encodable", resultType)
+		}
+	}
+	return nil
+}
+
+// verifyMethod : Func.Callが前提とするシグネチャか検証する
+// func(context.Context[, params]) ([result, ]error)
+func verifyMethod(method interface{}) error {
+	funcType := reflect.TypeOf(method)
+	if funcType.Kind() != reflect.Func {
+		return errors.Errorf("method must be a func, got %s", funcType)
+	}
+	if funcType.IsVariadic() {
+		return errors.Errorf("method must not be variadic: %s", funcType)
+	}
+	if funcType.NumIn() < 1 || 2 < funcType.NumIn() {
+		return errors.Errorf("method must take 1 or 2 arguments: %s", funcType)
+	}
+	if funcType.In(0) != contextType {
+		return errors.Errorf("first argument must be context.Context: %s", funcType)
+	}
+	if funcType.NumOut() < 1 || 2 < funcType.NumOut() {
+		return errors.Errorf("method must return 1 or 2 values: %s", funcType)
+	}
+	if funcType.Out(funcType.NumOut()-1) != errorType {
+		return errors.Errorf("last return value must be error: %s", funcType)
+	}
+	return nil
+}
+
+// verifyJSONType : encoding/jsonで扱えない型が含まれていないか検証する
+func verifyJSONType(t reflect.Type, decode bool, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	// 独自のMarshal/Unmarshalを持つ型はそれに任せる
+	if decode && (reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType)) {
+		return nil
+	}
+	if !decode && (t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return errors.Errorf("unsupported type %s", t)
+	case reflect.Interface:
+		// 空でないinterfaceにはデコードできない
+		if decode && t.NumMethod() != 0 {
+			return errors.Errorf("cannot decode into interface %s", t)
+		}
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return verifyJSONType(t.Elem(), decode, seen)
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) && !t.Key().Implements(textMarshalerType) {
+				return errors.Errorf("unsupported map key type %s", t.Key())
+			}
+		}
+		return verifyJSONType(t.Elem(), decode, seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if _, ok := jsonFieldName(field); !ok {
+				continue
+			}
+			if err := verifyJSONType(field.Type, decode, seen); err != nil {
+				return errors.Wrapf(err, "field %s", field.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/errof"
+	"github.com/httptest/backend/pkg/firebase"
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/ratelimit"
+	"github.com/httptest/backend/pkg/tracing"
+	"github.com/httptest/backend/pkg/util"
+	"github.com/httptest/backend/rpc/usecase"
+	"github.com/inconshreveable/log15"
+)
+
+const (
+	defaultPingPeriod = 50 * time.Second
+	defaultPongWait   = 60 * time.Second
+	defaultWriteWait  = 10 * time.Second
+	defaultReadLimit  = 1 << 20
+)
+
+// WebSocketHandler :
+type WebSocketHandler interface {
+	http.Handler
+	// Shutdown : 接続中の全てのWebSocketを閉じる、http.Server.RegisterOnShutdownに渡す
+	Shutdown()
+}
+
+type webSocketHandler struct {
+	rpc      rpcHandler
+	upgrader websocket.Upgrader
+	config   config.WebSocket
+
+	mu       sync.Mutex
+	conns    map[*websocket.Conn]struct{}
+	shutdown bool
+}
+
+// NewWebSocketHandler : firebaseHandlerと同じメソッドをWebSocketで提供する
+func NewWebSocketHandler(
+	c config.HTTP,
+	verifier firebase.Verifier,
+	roles RoleResolver,
+	orgs OrgResolver,
+	devices DeviceStore,
+	rateLimiter ratelimit.Store,
+	successUsecase usecase.Success,
+) (WebSocketHandler, error) {
+	rpc, err := newFirebaseHandler(c, verifier, roles, orgs, devices, rateLimiter, successUsecase)
+	if err != nil {
+		return nil, err
+	}
+
+	wc := c.WebSocket
+	if wc.PingPeriod <= 0 {
+		wc.PingPeriod = defaultPingPeriod
+	}
+	if wc.PongWait <= 0 {
+		wc.PongWait = defaultPongWait
+	}
+	if wc.WriteWait <= 0 {
+		wc.WriteWait = defaultWriteWait
+	}
+	if wc.ReadLimit <= 0 {
+		wc.ReadLimit = defaultReadLimit
+	}
+
+	allowOrigin := c.Cors
+	return &webSocketHandler{
+		rpc: rpc,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || allowOrigin == "*" || origin == allowOrigin
+			},
+		},
+		config: wc,
+		conns:  map[*websocket.Conn]struct{}{},
+	}, nil
+}
+
+func (h *webSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 認証はupgrade時に1度だけ行い、IDトークンの有効期限で接続を閉じる
+	start := time.Now()
+	// 接続が閉じたら実行中のメソッドもキャンセルする
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// 接続中のspanは長くなるため作らず、フレーム毎の呼び出しのspanの親にする
+	ctx = tracing.Extract(ctx, r.Header)
+	ctx = util.SetRequestID(ctx, requestID(r))
+	ctx = util.SetIPAddress(ctx, h.rpc.ips.ClientIP(r))
+	ctx = util.SetLang(ctx, errof.MatchLang(r.Header.Get("Accept-Language")))
+	if err := h.rpc.allowAuth(ctx); err != nil {
+		if rateLimited, ok := rateLimitError(err); ok {
+			w.Header().Set("Retry-After", strconv.FormatInt(rateLimited.RetryAfterSeconds(), 10))
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		logRequest(ctx, r, http.StatusTooManyRequests, false, start)
+		return
+	}
+	ctx, err := h.rpc.auth.Authenticate(ctx, r)
+	if err != nil {
+		h.rpc.failAuth(ctx)
+		w.WriteHeader(http.StatusUnauthorized)
+		logRequest(ctx, r, http.StatusUnauthorized, false, start)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, http.Header{XRequestID.String(): {util.GetRequestID(ctx)}})
+	if err != nil {
+		// Upgradeがエラーのレスポンスを書き込み済み
+		log15.Warn("Failed to upgrade websocket", "err", err)
+		return
+	}
+	if !h.add(conn) {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"),
+			time.Now().Add(h.config.WriteWait))
+		conn.Close()
+		return
+	}
+	defer h.remove(conn)
+
+	// 接続毎に1行、接続していた時間とフレーム数を残す
+	var frames int
+	defer func() {
+		logRequest(ctx, r, http.StatusSwitchingProtocols, true, start, "frames", frames)
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go h.ping(conn, done)
+
+	expiry := util.GetTokenExpiry(ctx)
+	if !expiry.IsZero() {
+		timer := time.AfterFunc(expiry.Sub(util.TimeNowFunc()), func() {
+			h.closeExpired(conn)
+		})
+		defer timer.Stop()
+	}
+
+	conn.SetReadLimit(h.config.ReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(h.config.PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(h.config.PongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log15.Warn("Websocket closed unexpectedly", "err", err)
+			}
+			return
+		}
+		// タイマーより先にフレームが届いた場合も実行しない
+		if !expiry.IsZero() && !util.TimeNowFunc().Before(expiry) {
+			h.closeExpired(conn)
+			return
+		}
+		frames++
+		returns, batch := h.exec(ctx, data)
+		if err = h.write(conn, batch, returns); err != nil {
+			log15.Crit("Failed to write websocket response", "err", err)
+			return
+		}
+	}
+}
+
+// exec : 1フレーム分のリクエスト(単一 or バッチ)を実行する
+// batchはリクエストがバッチで、バッチの結果を返す場合のみtrue
+func (h *webSocketHandler) exec(ctx context.Context, data []byte) (returns []*jsonrpc.Return, batch bool) {
+	requests, batch, err := jsonrpc.ParseBytes(data, h.rpc.limits)
+	if err != nil {
+		return handleReturn(ctx, nil, nil, err), false
+	}
+	if err = h.rpc.allowRequest(ctx, len(requests)); err != nil {
+		return handleReturn(ctx, nil, nil, err), false
+	}
+	return h.rpc.execBatch(ctx, requests), batch
+}
+
+func (h *webSocketHandler) write(conn *websocket.Conn, batch bool, returns []*jsonrpc.Return) error {
+	// 通知のみの場合は何も返さない
+	if len(jsonrpc.Responses(returns)) == 0 {
+		return nil
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(h.config.WriteWait))
+	w, err := conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	if err = jsonrpc.WriteResponses(w, batch, returns...); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// closeExpired : IDトークンの有効期限が切れた接続を閉じる、クライアントは新しいトークンで接続し直す
+func (h *webSocketHandler) closeExpired(conn *websocket.Conn) {
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token expired"),
+		time.Now().Add(h.config.WriteWait))
+	conn.Close()
+}
+
+// ping : 接続が閉じられるまで定期的にpingを送る
+func (h *webSocketHandler) ping(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(h.config.PingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// WriteControlは他の書き込みと並行して呼び出せる
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.config.WriteWait)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (h *webSocketHandler) add(conn *websocket.Conn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shutdown {
+		return false
+	}
+	h.conns[conn] = struct{}{}
+	return true
+}
+
+func (h *webSocketHandler) remove(conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, conn)
+	conn.Close()
+}
+
+func (h *webSocketHandler) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = true
+	for conn := range h.conns {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"),
+			time.Now().Add(h.config.WriteWait))
+		conn.Close()
+	}
+}
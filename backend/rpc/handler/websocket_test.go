@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/httptest/backend/pkg/config"
+	"github.com/httptest/backend/pkg/firebase"
+	"github.com/httptest/backend/pkg/jsonrpc"
+	"github.com/httptest/backend/pkg/ratelimit"
+)
+
+type testSuccess struct{}
+
+func (testSuccess) GetSuccess(ctx context.Context) (string, error) {
+	return "success", nil
+}
+
+// expiringVerifier : トークンをそのままUIDとし、有効期限をexpiresAtにする
+type expiringVerifier struct {
+	expiresAt time.Time
+}
+
+func (v expiringVerifier) Verify(_ context.Context, idToken string) (*firebase.Token, error) {
+	return &firebase.Token{
+		Credential: jsonrpc.Credential{UID: idToken},
+		Claims:     map[string]interface{}{},
+		ExpiresAt:  v.expiresAt,
+	}, nil
+}
+
+func (expiringVerifier) Ready(context.Context) error {
+	return nil
+}
+
+func dialWebSocket(t *testing.T, verifier firebase.Verifier) *websocket.Conn {
+	t.Helper()
+	h, err := NewWebSocketHandler(config.HTTP{Cors: "*"}, verifier, NewRoleResolver(), NewOrgResolver(), NewDeviceStore(), ratelimit.NewStore(), testSuccess{})
+	if err != nil {
+		t.Fatalf("NewWebSocketHandler() error = %v", err)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	header := http.Header{}
+	header.Set(Authorization.String(), "Bearer uid-1")
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWebSocketCloseOnTokenExpiry(t *testing.T) {
+	conn := dialWebSocket(t, expiringVerifier{expiresAt: time.Now().Add(300 * time.Millisecond)})
+
+	// 有効期限までは呼び出せる
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"getSuccess","id":1}`)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	if _, b, err := conn.ReadMessage(); err != nil || !strings.Contains(string(b), `"result":"success"`) {
+		t.Fatalf("ReadMessage() = %s, %v", b, err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("ReadMessage() error = %v, want close %d", err, websocket.ClosePolicyViolation)
+	}
+}
+
+func TestWebSocketWithoutExpiry(t *testing.T) {
+	conn := dialWebSocket(t, expiringVerifier{})
+	for i := 0; i < 2; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`[{"jsonrpc":"2.0","method":"getSuccess","id":1}]`)); err != nil {
+			t.Fatalf("WriteMessage() error = %v", err)
+		}
+		// バッチの結果は1つでも配列で返す
+		if _, b, err := conn.ReadMessage(); err != nil || !strings.HasPrefix(string(b), "[") {
+			t.Fatalf("ReadMessage() = %s, %v", b, err)
+		}
+	}
+}
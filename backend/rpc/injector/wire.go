@@ -8,17 +8,25 @@ import (
 	"github.com/google/wire"
 	"github.com/httptest/backend/pkg/config"
 	"github.com/httptest/backend/pkg/db"
+	"github.com/httptest/backend/pkg/firebase"
+	"github.com/httptest/backend/pkg/health"
+	"github.com/httptest/backend/pkg/ratelimit"
 	"github.com/httptest/backend/rpc/handler"
+	"github.com/httptest/backend/rpc/usecase"
 )
 
 // FirebaseFuncMap :
 var FirebaseFuncMap = wire.NewSet(
 	db.NewPSQL,
 	db.NewDB,
+	handler.NewRoleResolver,
+	handler.NewOrgResolver,
+	handler.NewDeviceStore,
+	usecase.NewSuccess,
 )
 
 // InitializeFirebaseMap :
-func InitializeFirebaseMap(config.Postgres, config.Firebase, string) (_ map[string]handler.Func) {
+func InitializeFirebaseMap(config.Postgres, string) (_ map[string]handler.Func) {
 	wire.Build(
 		handler.GetFirebaseFuncMap,
 		FirebaseFuncMap,
@@ -27,10 +35,55 @@ func InitializeFirebaseMap(config.Postgres, config.Firebase, string) (_ map[stri
 }
 
 // InitializeFirebaseHandler :
-func InitializeFirebaseHandler(config.HTTP, config.Postgres, config.Firebase, string) (_ http.Handler) {
+func InitializeFirebaseHandler(config.HTTP, config.Postgres, firebase.Verifier, string, ratelimit.Store) (_ http.Handler, _ error) {
 	wire.Build(
 		handler.NewFirebaseHandler,
 		FirebaseFuncMap,
 	)
 	return
 }
+
+// InitializePublicHandler :
+func InitializePublicHandler(config.HTTP, config.Postgres, string, ratelimit.Store) (_ http.Handler, _ error) {
+	wire.Build(
+		handler.NewPublicHandler,
+		FirebaseFuncMap,
+	)
+	return
+}
+
+// InitializeInternalHandler :
+func InitializeInternalHandler(config.HTTP, config.Internal, config.Postgres, string, ratelimit.Store) (_ http.Handler, _ error) {
+	wire.Build(
+		handler.NewInternalHandler,
+		FirebaseFuncMap,
+	)
+	return
+}
+
+// InitializeWebSocketHandler :
+func InitializeWebSocketHandler(config.HTTP, config.Postgres, firebase.Verifier, string, ratelimit.Store) (_ handler.WebSocketHandler, _ error) {
+	wire.Build(
+		handler.NewWebSocketHandler,
+		FirebaseFuncMap,
+	)
+	return
+}
+
+// InitializeOpenRPCHandler :
+func InitializeOpenRPCHandler(config.HTTP, config.Postgres, string) (_ http.Handler) {
+	wire.Build(
+		handler.NewOpenRPCHandler,
+		FirebaseFuncMap,
+	)
+	return
+}
+
+// InitializeHealthChecker :
+func InitializeHealthChecker(config.Health, config.Postgres, firebase.Verifier, string) (_ *health.Checker, _ func(), _ error) {
+	wire.Build(
+		handler.NewHealthChecker,
+		FirebaseFuncMap,
+	)
+	return
+}
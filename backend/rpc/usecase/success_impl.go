@@ -5,7 +5,14 @@ import (
 	"fmt"
 )
 
-func GetSuccess(ctx context.Context) (result string, err error) {
+type success struct{}
+
+// NewSuccess :
+func NewSuccess() Success {
+	return success{}
+}
+
+func (success) GetSuccess(ctx context.Context) (result string, err error) {
 	result = "success"
 	fmt.Println(result)
 	return result, nil